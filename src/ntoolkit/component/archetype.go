@@ -0,0 +1,407 @@
+package component
+
+import (
+	"reflect"
+	"sort"
+
+	"ntoolkit/errors"
+	"ntoolkit/iter"
+)
+
+// IndexMode selects how a runtime indexes components for queries. Components always live on their
+// objects; the mode only changes how queries across the runtime find them.
+type IndexMode int
+
+const (
+	// ComponentIndex indexes the objects in the runtime by each component type they have; queries for
+	// several types start from the objects with one of them and check each object for the rest.
+	ComponentIndex IndexMode = iota
+
+	// ArchetypeIndex indexes the objects in the runtime by their set of component types, into archetype
+	// tables with a column for each type. The columns hold references to the components, not the
+	// component data. Queries for several types only visit the tables that have all of them, and read
+	// the components from the columns in order without copying them. Adding or removing components
+	// costs a little more, as the object moves to another table.
+	ArchetypeIndex
+)
+
+// archetype is a table of the objects that have exactly the same set of component types.
+// Each column holds the first component of its type on each object, in the same row order as objects.
+// Queries return the rows without copying them, so a table that has been queried is copied before it
+// is next changed in place.
+type archetype struct {
+	key     uint64         // The sum of the type ids of the table; see typeID
+	types   []reflect.Type // The component types of the table, sorted by name
+	objects []*Object
+	columns [][]Component
+	shared  bool // The rows have been returned by a query since they were last copied
+}
+
+// archetypeRow locates an object in its table
+type archetypeRow struct {
+	table *archetype
+	row   int
+}
+
+// archetypeStore holds the archetype tables of a runtime; it is guarded by the runtime index lock.
+type archetypeStore struct {
+	tables  map[uint64][]*archetype // The tables for each key; different type sets rarely share a key
+	rows    map[*Object]archetypeRow
+	typeIDs map[reflect.Type]uint64
+}
+
+func newArchetypeStore() *archetypeStore {
+	return &archetypeStore{
+		tables:  make(map[uint64][]*archetype),
+		rows:    make(map[*Object]archetypeRow),
+		typeIDs: make(map[reflect.Type]uint64)}
+}
+
+// typeID returns a random looking id for a component type; the ids of a set of types are summed into
+// the key of its table, so the key does not depend on the order of the types.
+func (store *archetypeStore) typeID(T reflect.Type) uint64 {
+	if id, ok := store.typeIDs[T]; ok {
+		return id
+	}
+	// splitmix64 of the number of types seen so far
+	id := uint64(len(store.typeIDs)+1) * 0x9e3779b97f4a7c15
+	id = (id ^ (id >> 30)) * 0xbf58476d1ce4e5b9
+	id = (id ^ (id >> 27)) * 0x94d049bb133111eb
+	id ^= id >> 31
+	store.typeIDs[T] = id
+	return id
+}
+
+// matches checks if the table is for exactly the given set of types
+func (table *archetype) matches(key uint64, types map[reflect.Type][]*componentInfo) bool {
+	if table.key != key || len(table.types) != len(types) {
+		return false
+	}
+	for i := 0; i < len(table.types); i++ {
+		if _, ok := types[table.types[i]]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// place moves an object into the table for its current component types
+func (store *archetypeStore) place(object *Object) {
+	types := object.types
+	if len(types) == 0 {
+		store.remove(object)
+		return
+	}
+	key := uint64(0)
+	for T := range types {
+		key += store.typeID(T)
+	}
+
+	if current, ok := store.rows[object]; ok && current.table.matches(key, types) {
+		// Same types; the first component of a type may still have changed
+		table := current.table
+		for i := 0; i < len(table.types); i++ {
+			if first := types[table.types[i]][0].Component; table.columns[i][current.row] != first {
+				table.own()
+				table.columns[i][current.row] = first
+			}
+		}
+		return
+	}
+	store.remove(object)
+	var table *archetype
+	for _, candidate := range store.tables[key] {
+		if candidate.matches(key, types) {
+			table = candidate
+			break
+		}
+	}
+	if table == nil {
+		table = newArchetype(key, types)
+		store.tables[key] = append(store.tables[key], table)
+	}
+	store.rows[object] = archetypeRow{table: table, row: len(table.objects)}
+	table.objects = append(table.objects, object)
+	for i := 0; i < len(table.types); i++ {
+		table.columns[i] = append(table.columns[i], types[table.types[i]][0].Component)
+	}
+}
+
+// newArchetype returns an empty table for a set of types
+func newArchetype(key uint64, types map[reflect.Type][]*componentInfo) *archetype {
+	sorted := make([]reflect.Type, 0, len(types))
+	for T := range types {
+		sorted = append(sorted, T)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return typeName(sorted[i]) < typeName(sorted[j]) })
+	return &archetype{key: key, types: sorted, columns: make([][]Component, len(sorted))}
+}
+
+// own copies the rows of the table if a query has returned them, so they can be changed in place.
+// Appending never changes the rows a query has, so only changes to existing rows need this.
+func (table *archetype) own() {
+	if !table.shared {
+		return
+	}
+	table.objects = append(make([]*Object, 0, cap(table.objects)), table.objects...)
+	for i := 0; i < len(table.columns); i++ {
+		table.columns[i] = append(make([]Component, 0, cap(table.columns[i])), table.columns[i]...)
+	}
+	table.shared = false
+}
+
+// remove takes an object out of its table, if it is in one, by moving the last row into its place
+func (store *archetypeStore) remove(object *Object) {
+	current, ok := store.rows[object]
+	if !ok {
+		return
+	}
+	delete(store.rows, object)
+	table := current.table
+	table.own()
+	last := len(table.objects) - 1
+	if current.row != last {
+		moved := table.objects[last]
+		table.objects[current.row] = moved
+		for i := 0; i < len(table.columns); i++ {
+			table.columns[i][current.row] = table.columns[i][last]
+		}
+		store.rows[moved] = archetypeRow{table: table, row: current.row}
+	}
+	table.objects[last] = nil
+	table.objects = table.objects[:last]
+	for i := 0; i < len(table.columns); i++ {
+		table.columns[i][last] = nil
+		table.columns[i] = table.columns[i][:last]
+	}
+}
+
+// column returns the index of the column for T in the table, or -1. If T is an interface, the first
+// column with a type that implements it is used.
+func (table *archetype) column(T reflect.Type) int {
+	for i := 0; i < len(table.types); i++ {
+		if table.types[i] == T {
+			return i
+		}
+	}
+	if T.Kind() == reflect.Interface {
+		for i := 0; i < len(table.types); i++ {
+			if table.types[i].Implements(T) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// queryResult is a read only view of the matching rows of one table, that can be visited without
+// holding the index lock; the table is copied before it is changed.
+type queryResult struct {
+	objects []*Object
+	columns [][]Component // The columns for each of the requested types, in the order requested
+}
+
+// query returns a view of the matching rows of every table that has all of the given types
+func (store *archetypeStore) query(types []reflect.Type) []queryResult {
+	rtn := make([]queryResult, 0)
+	for _, tables := range store.tables {
+		for _, table := range tables {
+			if len(table.objects) == 0 {
+				continue
+			}
+			result := queryResult{columns: make([][]Component, len(types))}
+			matched := true
+			for i := 0; i < len(types) && matched; i++ {
+				column := table.column(types[i])
+				if column < 0 {
+					matched = false
+				} else {
+					result.columns[i] = table.columns[column][:len(table.objects):len(table.objects)]
+				}
+			}
+			if matched {
+				result.objects = table.objects[:len(table.objects):len(table.objects)]
+				table.shared = true
+				rtn = append(rtn, result)
+			}
+		}
+	}
+	return rtn
+}
+
+// queryIter is an iterator over the objects of a set of query results, without copying them
+type queryIter struct {
+	results []queryResult
+	table   int
+	row     int
+}
+
+// Next returns the next object
+func (iterator *queryIter) Next() (interface{}, error) {
+	for iterator.table < len(iterator.results) {
+		objects := iterator.results[iterator.table].objects
+		if iterator.row < len(objects) {
+			iterator.row += 1
+			return objects[iterator.row-1], nil
+		}
+		iterator.table += 1
+		iterator.row = 0
+	}
+	return nil, errors.Fail(iter.ErrEndIteration{}, nil, "No more values")
+}
+
+// IndexMode returns how the runtime indexes components for queries.
+func (runtime *Runtime) IndexMode() IndexMode {
+	if runtime.archetypes != nil {
+		return ArchetypeIndex
+	}
+	return ComponentIndex
+}
+
+// queryComponents returns the objects in the runtime with a component of every given type, and the
+// first such component of each type, grouped into archetype tables if the runtime uses them.
+func (runtime *Runtime) queryComponents(types []reflect.Type) []queryResult {
+	if len(types) == 0 {
+		return nil
+	}
+	runtime.indexLock.Lock()
+	defer runtime.indexLock.Unlock()
+	if runtime.archetypes != nil {
+		return runtime.archetypes.query(types)
+	}
+
+	// Without archetypes, start from the index for the first concrete type and check the rest
+	objects := make([]*Object, 0)
+	concrete := -1
+	for i := 0; i < len(types) && concrete < 0; i++ {
+		if types[i].Kind() != reflect.Interface {
+			concrete = i
+		}
+	}
+	if concrete >= 0 {
+		for object := range runtime.index[types[concrete]] {
+			objects = append(objects, object)
+		}
+	} else {
+		seen := make(map[*Object]bool)
+		for T, indexed := range runtime.index {
+			if T.Implements(types[0]) {
+				for object := range indexed {
+					if !seen[object] {
+						seen[object] = true
+						objects = append(objects, object)
+					}
+				}
+			}
+		}
+	}
+	result := queryResult{objects: make([]*Object, 0, len(objects)), columns: make([][]Component, len(types))}
+	for _, object := range objects {
+		row := make([]Component, len(types))
+		matched := true
+		for i := 0; i < len(types) && matched; i++ {
+			row[i] = object.firstComponent(types[i])
+			matched = row[i] != nil
+		}
+		if matched {
+			result.objects = append(result.objects, object)
+			for i := 0; i < len(types); i++ {
+				result.columns[i] = append(result.columns[i], row[i])
+			}
+		}
+	}
+	return []queryResult{result}
+}
+
+// firstComponent returns the first component of type T on the object, or one that implements T if
+// it is an interface, or nil.
+func (o *Object) firstComponent(T reflect.Type) Component {
+	if T.Kind() != reflect.Interface {
+		if components := o.types[T]; len(components) > 0 {
+			return components[0].Component
+		}
+		return nil
+	}
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if components[i].Type.Implements(T) {
+			return components[i].Component
+		}
+	}
+	return nil
+}
+
+// ObjectsWithComponents returns an iterator of every object in the runtime, including the root, that
+// has a component of each of the given types; with no types there are no objects. With
+// ArchetypeIndex only the matching tables are visited. The objects are returned in no particular order.
+func (runtime *Runtime) ObjectsWithComponents(types ...reflect.Type) iter.Iter {
+	return &queryIter{results: runtime.queryComponents(types)}
+}
+
+// Each returns an iterator over every object in the runtime with a component of type A, and that
+// component, for use with range. The objects are visited in no particular order; with
+// ArchetypeIndex they are read from the archetype columns. Changes made while iterating are not
+// seen by the iterator.
+func Each[A Component](runtime *Runtime) func(yield func(*Object, A) bool) {
+	return func(yield func(*Object, A) bool) {
+		results := runtime.queryComponents([]reflect.Type{componentType[A]()})
+		for _, result := range results {
+			a := result.columns[0]
+			for i := 0; i < len(result.objects); i++ {
+				if !yield(result.objects[i], a[i].(A)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Match2 holds the components found on an object by Each2.
+type Match2[A Component, B Component] struct {
+	A A
+	B B
+}
+
+// Match3 holds the components found on an object by Each3.
+type Match3[A Component, B Component, C Component] struct {
+	A A
+	B B
+	C C
+}
+
+// Each2 returns an iterator over every object in the runtime with components of both type A and B,
+// and those components; see Each.
+func Each2[A Component, B Component](runtime *Runtime) func(yield func(*Object, Match2[A, B]) bool) {
+	return func(yield func(*Object, Match2[A, B]) bool) {
+		results := runtime.queryComponents([]reflect.Type{componentType[A](), componentType[B]()})
+		for _, result := range results {
+			a, b := result.columns[0], result.columns[1]
+			for i := 0; i < len(result.objects); i++ {
+				if !yield(result.objects[i], Match2[A, B]{a[i].(A), b[i].(B)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Each3 returns an iterator over every object in the runtime with components of type A, B and C, and
+// those components; see Each.
+func Each3[A Component, B Component, C Component](runtime *Runtime) func(yield func(*Object, Match3[A, B, C]) bool) {
+	return func(yield func(*Object, Match3[A, B, C]) bool) {
+		results := runtime.queryComponents([]reflect.Type{componentType[A](), componentType[B](), componentType[C]()})
+		for _, result := range results {
+			a, b, c := result.columns[0], result.columns[1], result.columns[2]
+			for i := 0; i < len(result.objects); i++ {
+				if !yield(result.objects[i], Match3[A, B, C]{a[i].(A), b[i].(B), c[i].(C)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// componentType returns the reflect type of a component type parameter
+func componentType[T Component]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,184 @@
+package component_test
+
+import (
+	"fmt"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"ntoolkit/iter"
+	"reflect"
+	"testing"
+)
+
+// buildMovers adds count objects with a FakePosition to the runtime; every other one also has a FakeVelocity
+func buildMovers(runtime *component.Runtime, count int) {
+	for i := 0; i < count; i++ {
+		obj := component.NewObject(fmt.Sprintf("Mover %d", i))
+		obj.AddComponent(&FakePosition{})
+		if i%2 == 0 {
+			obj.AddComponent(&FakeVelocity{X: 1, Y: 2})
+		}
+		runtime.Root().AddObject(obj)
+	}
+}
+
+func TestEach(T *testing.T) {
+	for _, mode := range []component.IndexMode{component.ComponentIndex, component.ArchetypeIndex} {
+		assert.Test(T, func(T *assert.T) {
+			runtime := component.NewRuntime(component.Config{Index: mode})
+			T.Assert(runtime.IndexMode() == mode)
+			buildMovers(runtime, 10)
+
+			count := 0
+			for range component.Each[*FakePosition](runtime) {
+				count += 1
+			}
+			T.Assert(count == 10)
+
+			count = 0
+			for obj, match := range component.Each2[*FakePosition, *FakeVelocity](runtime) {
+				p, _ := component.GetComponent[*FakePosition](obj)
+				T.Assert(p == match.A)
+				match.A.X += match.B.X
+				count += 1
+			}
+			T.Assert(count == 5)
+
+			moved := 0
+			for _, position := range component.Each[*FakePosition](runtime) {
+				if position.X == 1 {
+					moved += 1
+				}
+			}
+			T.Assert(moved == 5)
+
+			// Interfaces match any component that implements them
+			count = 0
+			for range component.Each2[component.Component, *FakeVelocity](runtime) {
+				count += 1
+			}
+			T.Assert(count == 5)
+
+			count = 0
+			for _, match := range component.Each3[*FakePosition, *FakeVelocity, component.Component](runtime) {
+				T.Assert(match.C != nil)
+				count += 1
+			}
+			T.Assert(count == 5)
+
+			// No types match no objects
+			_, err := runtime.ObjectsWithComponents().Next()
+			T.Assert(errors.Is(err, iter.ErrEndIteration{}))
+		})
+	}
+}
+
+func TestArchetypeChanges(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{Index: component.ArchetypeIndex})
+		buildMovers(runtime, 4)
+		both := []reflect.Type{reflect.TypeOf(&FakePosition{}), reflect.TypeOf(&FakeVelocity{})}
+
+		obj, _ := runtime.Root().GetObject("Mover 1")
+		T.Assert(!objectIn(runtime.ObjectsWithComponents(both...), obj))
+		obj.AddComponent(&FakeVelocity{})
+		T.Assert(objectIn(runtime.ObjectsWithComponents(both...), obj))
+
+		velocity, _ := component.GetComponent[*FakeVelocity](obj)
+		obj.RemoveComponent(velocity)
+		T.Assert(!objectIn(runtime.ObjectsWithComponents(both...), obj))
+		T.Assert(objectIn(runtime.ObjectsWithComponents(both[0]), obj))
+
+		other, _ := runtime.Root().GetObject("Mover 0")
+		runtime.Root().RemoveObject(other)
+		T.Assert(!objectIn(runtime.ObjectsWithComponents(both[0]), other))
+
+		count := 0
+		for range component.Each2[*FakePosition, *FakeVelocity](runtime) {
+			count += 1
+		}
+		T.Assert(count == 1)
+	})
+}
+
+func TestArchetypeQueryIsUnchangedByChanges(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{Index: component.ArchetypeIndex})
+		buildMovers(runtime, 10)
+
+		// Removing velocities while iterating does not change the rows being visited
+		count := 0
+		for obj, match := range component.Each2[*FakePosition, *FakeVelocity](runtime) {
+			T.Assert(obj.RemoveComponent(match.B) == nil)
+			count += 1
+		}
+		T.Assert(count == 5)
+
+		count = 0
+		for range component.Each2[*FakePosition, *FakeVelocity](runtime) {
+			count += 1
+		}
+		T.Assert(count == 0)
+		objects, _ := iter.Collect(runtime.ObjectsWithComponents(reflect.TypeOf(&FakePosition{})))
+		T.Assert(len(objects) == 10)
+	})
+}
+
+// objectIn checks if an object iterator returns the given object
+func objectIn(objects iter.Iter, target *component.Object) bool {
+	for value, err := objects.Next(); err == nil; value, err = objects.Next() {
+		if value.(*component.Object) == target {
+			return true
+		}
+	}
+	return false
+}
+
+func benchmarkEach2(b *testing.B, mode component.IndexMode) {
+	runtime := component.NewRuntime(component.Config{Index: mode})
+	buildMovers(runtime, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, match := range component.Each2[*FakePosition, *FakeVelocity](runtime) {
+			match.A.X += match.B.X
+		}
+	}
+}
+
+func BenchmarkEach2ComponentIndex100k(b *testing.B) {
+	benchmarkEach2(b, component.ComponentIndex)
+}
+
+func BenchmarkEach2ArchetypeIndex100k(b *testing.B) {
+	benchmarkEach2(b, component.ArchetypeIndex)
+}
+
+// BenchmarkObjectsWithComponent100k finds the same objects as BenchmarkEach2ArchetypeIndex100k
+// through the component index, for comparison
+func BenchmarkObjectsWithComponent100k(b *testing.B) {
+	runtime := component.NewRuntime(component.Config{})
+	buildMovers(runtime, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		objects := runtime.ObjectsWithComponent(reflect.TypeOf(&FakeVelocity{}))
+		for value, err := objects.Next(); err == nil; value, err = objects.Next() {
+			obj := value.(*component.Object)
+			position, _ := component.GetComponent[*FakePosition](obj)
+			velocity, _ := component.GetComponent[*FakeVelocity](obj)
+			position.X += velocity.X
+		}
+	}
+}
+
+func BenchmarkArchetypeChanges100k(b *testing.B) {
+	runtime := component.NewRuntime(component.Config{Index: component.ArchetypeIndex})
+	buildMovers(runtime, 100000)
+	obj, _ := runtime.Root().GetObject("Mover 1")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		velocity := &FakeVelocity{}
+		obj.AddComponent(velocity)
+		obj.RemoveComponent(velocity)
+	}
+}
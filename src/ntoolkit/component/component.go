@@ -1,8 +1,8 @@
 package component
 
 import (
+	"log/slog"
 	"reflect"
-	"log"
 )
 
 // Component is a unit of functionality that can be attached to objects.
@@ -38,10 +38,52 @@ type Update interface {
 	Update(context *Context)
 }
 
+// OnEnable components are notified when they become active.
+type OnEnable interface {
+	// OnEnable is invoked when the component is enabled, or its object becomes active in the hierarchy.
+	OnEnable(context *Context)
+}
+
+// OnDisable components are notified when they stop being active.
+type OnDisable interface {
+	// OnDisable is invoked when the component is disabled, or its object stops being active in the hierarchy.
+	OnDisable(context *Context)
+}
+
+// OnDestroy components are notified when their object is destroyed or the runtime is closed.
+type OnDestroy interface {
+	// OnDestroy is invoked once, to release any resources the component holds.
+	OnDestroy(context *Context) error
+}
+
+// Access components use components or shared resources on other objects.
+type Access interface {
+	// Access returns the component types and resources the component reads and writes outside of its
+	// own object. Components with conflicting access are never updated at the same time.
+	Access() ComponentAccess
+}
+
+// Requires components only work when other components are on the same object.
+type Requires interface {
+	// Requires returns the component types that must also be on the object.
+	// A type may be an interface, in which case any component that implements it will do.
+	Requires() []reflect.Type
+}
+
 // Context provides a reference back to the owning game object and runtime state for a component
 type Context struct {
-	Object    *Object     // The object the component is attached to.
-	DeltaTime float32     // The delta step in global time for the update.
-	Logger    *log.Logger // The runtime logger.
-	Runtime   *Runtime
+	Object            *Object      // The object the component is attached to.
+	DeltaTime         float32      // The delta step for the update, scaled by the time scale of the object.
+	UnscaledDeltaTime float32      // The delta step in global time for the update.
+	Logger            *slog.Logger // The runtime logger, annotated with the object and component.
+	Runtime           *Runtime
+	Component         Component // The component being invoked, if any.
+}
+
+// forComponent returns a copy of the context for invoking a component
+func (context *Context) forComponent(info *componentInfo) *Context {
+	rtn := *context
+	rtn.Component = info.Component
+	rtn.Logger = info.componentLogger(context.Object, context.Runtime)
+	return &rtn
 }
@@ -0,0 +1,246 @@
+package component
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strconv"
+	"sync"
+)
+
+// ComponentAccess declares what a component uses outside of its own object: the types of the
+// components it reads and writes on other objects, and any named resources shared between
+// components, such as a score board or a network connection. Changes a component makes to its own
+// object are local, and are not checked against other objects; if an object changes a type that
+// other objects read, it should declare that type in Writes too.
+type ComponentAccess struct {
+	Reads          []reflect.Type
+	Writes         []reflect.Type
+	ReadResources  []string
+	WriteResources []string
+}
+
+// AccessViolation is an access to a component on another object that was not declared, found when
+// the runtime is configured with DebugAccess.
+type AccessViolation struct {
+	Component Component    // The component that made the access
+	Object    *Object      // The object being updated
+	Target    *Object      // The object that was accessed
+	Type      reflect.Type // The type of component that was accessed
+}
+
+// String returns a readable description of the violation.
+func (violation AccessViolation) String() string {
+	return fmt.Sprintf("Component %s on object '%s' accessed undeclared %s on object '%s'", typeName(violation.Component.Type()), violation.Object.name, typeName(violation.Type), violation.Target.name)
+}
+
+// merge returns an access with everything in both accesses, without duplicates
+func (access ComponentAccess) merge(other ComponentAccess) ComponentAccess {
+	return ComponentAccess{
+		Reads:          mergeTypes(access.Reads, other.Reads),
+		Writes:         mergeTypes(access.Writes, other.Writes),
+		ReadResources:  mergeResources(access.ReadResources, other.ReadResources),
+		WriteResources: mergeResources(access.WriteResources, other.WriteResources)}
+}
+
+// mergeTypes returns the types in a followed by those in b that are not in a; a is never changed
+func mergeTypes(a []reflect.Type, b []reflect.Type) []reflect.Type {
+	merged := a[:len(a):len(a)]
+	for i := 0; i < len(b); i++ {
+		if !containsType(merged, b[i]) {
+			merged = append(merged, b[i])
+		}
+	}
+	return merged
+}
+
+// mergeResources returns the resources in a followed by those in b that are not in a; a is never
+// changed
+func mergeResources(a []string, b []string) []string {
+	merged := a[:len(a):len(a)]
+	for i := 0; i < len(b); i++ {
+		if !containsResource(merged, b[i]) {
+			merged = append(merged, b[i])
+		}
+	}
+	return merged
+}
+
+// conflicts checks if one access writes something the other reads or writes
+func (access ComponentAccess) conflicts(other ComponentAccess) bool {
+	if typesOverlap(access.Writes, other.Reads) != nil || typesOverlap(access.Writes, other.Writes) != nil || typesOverlap(other.Writes, access.Reads) != nil {
+		return true
+	}
+	return resourcesOverlap(access.WriteResources, other.ReadResources) || resourcesOverlap(access.WriteResources, other.WriteResources) || resourcesOverlap(other.WriteResources, access.ReadResources)
+}
+
+// declares checks if the access includes reading or writing components of type T
+func (access ComponentAccess) declares(T reflect.Type) bool {
+	types := []reflect.Type{T}
+	return typesOverlap(access.Reads, types) != nil || typesOverlap(access.Writes, types) != nil
+}
+
+// resourcesOverlap checks if any resource name is in both sets
+func resourcesOverlap(a []string, b []string) bool {
+	for i := 0; i < len(a); i++ {
+		for j := 0; j < len(b); j++ {
+			if a[i] == b[j] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsType checks if a type is in a set of types
+func containsType(types []reflect.Type, T reflect.Type) bool {
+	for i := 0; i < len(types); i++ {
+		if types[i] == T {
+			return true
+		}
+	}
+	return false
+}
+
+// containsResource checks if a resource name is in a set of names
+func containsResource(resources []string, name string) bool {
+	for i := 0; i < len(resources); i++ {
+		if resources[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// objectAccess returns the combined access of the enabled components on an object, and whether any
+// of them declare access at all. Changes to the object's own components are local to it, so they
+// are not part of the access.
+func (o *Object) objectAccess() (ComponentAccess, bool) {
+	components := o.components
+	access := ComponentAccess{}
+	declared := false
+	for i := 0; i < len(components); i++ {
+		if components[i].Access != nil && !components[i].Disabled {
+			access = access.merge(components[i].Access.Access())
+			declared = true
+		}
+	}
+	return access, declared
+}
+
+// accessBatches splits objects into batches that have no conflicting access within them. Each object
+// goes into the batch after the last one it conflicts with, so conflicting objects are updated in
+// the order they are given.
+func accessBatches(objects []*Object, scales []float32, access []ComponentAccess) ([][]*Object, [][]float32) {
+	batches := make([][]*Object, 0)
+	batchScales := make([][]float32, 0)
+	combined := make([]ComponentAccess, 0)
+	for i := 0; i < len(objects); i++ {
+		batch := 0
+		for j := len(combined) - 1; j >= 0; j-- {
+			if combined[j].conflicts(access[i]) {
+				batch = j + 1
+				break
+			}
+		}
+		if batch == len(batches) {
+			batches = append(batches, nil)
+			batchScales = append(batchScales, nil)
+			combined = append(combined, ComponentAccess{})
+		}
+		batches[batch] = append(batches[batch], objects[i])
+		batchScales[batch] = append(batchScales[batch], scales[i])
+		combined[batch] = combined[batch].merge(access[i])
+	}
+	return batches, batchScales
+}
+
+// accessTracker records which component each worker goroutine is updating, to find undeclared
+// access to other objects; it is only used with DebugAccess.
+type accessTracker struct {
+	current    map[uint64]*componentInfo // The component being updated by each goroutine
+	objects    map[uint64]*Object        // The object being updated by each goroutine
+	violations []AccessViolation
+	lock       *sync.Mutex
+}
+
+func newAccessTracker() *accessTracker {
+	return &accessTracker{
+		current: make(map[uint64]*componentInfo),
+		objects: make(map[uint64]*Object),
+		lock:    &sync.Mutex{}}
+}
+
+// enter records that the calling goroutine is updating a component, or nothing if info is nil
+func (tracker *accessTracker) enter(object *Object, info *componentInfo) {
+	id := goroutineID()
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if info == nil {
+		delete(tracker.current, id)
+		delete(tracker.objects, id)
+	} else {
+		tracker.current[id] = info
+		tracker.objects[id] = object
+	}
+}
+
+// check records a violation if the calling goroutine is updating a component on another object that
+// did not declare access to T
+func (tracker *accessTracker) check(target *Object, T reflect.Type) *AccessViolation {
+	id := goroutineID()
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	info := tracker.current[id]
+	object := tracker.objects[id]
+	if info == nil || object == target {
+		return nil
+	}
+	if info.Access != nil && info.Access.Access().declares(T) {
+		return nil
+	}
+	violation := AccessViolation{Component: info.Component, Object: object, Target: target, Type: T}
+	tracker.violations = append(tracker.violations, violation)
+	return &violation
+}
+
+// goroutineID returns the id of the calling goroutine, from its stack trace. This is slow, and
+// is only used to debug access.
+func goroutineID() uint64 {
+	buffer := make([]byte, 64)
+	buffer = buffer[:goruntime.Stack(buffer, false)]
+	buffer = bytes.TrimPrefix(buffer, []byte("goroutine "))
+	buffer = buffer[:bytes.IndexByte(buffer, ' ')]
+	id, _ := strconv.ParseUint(string(buffer), 10, 64)
+	return id
+}
+
+// checkAccess records and logs an undeclared access to components of type T on the object, if the
+// runtime is debugging access
+func (o *Object) checkAccess(T reflect.Type) {
+	runtime := o.Runtime()
+	if runtime == nil || runtime.accessTracker == nil {
+		return
+	}
+	if violation := runtime.accessTracker.check(o, T); violation != nil && runtime.logger != nil {
+		runtime.logger.Warn("Access violation",
+			"component", typeName(violation.Component.Type()),
+			"object_id", violation.Object.id,
+			"object_path", violation.Object.Path(),
+			"target_id", violation.Target.id,
+			"target_path", violation.Target.Path(),
+			"type", typeName(T))
+	}
+}
+
+// AccessViolations returns the undeclared accesses found so far, if the runtime was configured with
+// DebugAccess.
+func (runtime *Runtime) AccessViolations() []AccessViolation {
+	if runtime.accessTracker == nil {
+		return nil
+	}
+	runtime.accessTracker.lock.Lock()
+	defer runtime.accessTracker.lock.Unlock()
+	return append([]AccessViolation(nil), runtime.accessTracker.violations...)
+}
@@ -0,0 +1,75 @@
+package component_test
+
+import (
+	"fmt"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"reflect"
+	"testing"
+)
+
+func TestConflictingAccessIsSerialized(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{ThreadPoolSize: 8})
+		board := &FakeScoreBoard{}
+		for i := 0; i < 200; i++ {
+			obj := component.NewObject(fmt.Sprintf("Player %d", i))
+			obj.AddComponent(&FakeScoreComponent{Board: board})
+			runtime.Root().AddObject(obj)
+		}
+		buildTree(runtime, 4, 100)
+
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(board.Score == 400)
+		T.Assert(board.Overlaps == 0)
+	})
+}
+
+func TestReadersRunInParallel(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{ThreadPoolSize: 4})
+		gauge := &FakeConcurrency{}
+		for i := 0; i < 256; i++ {
+			obj := component.NewObject(fmt.Sprintf("Reader %d", i))
+			obj.AddComponent(&FakePosition{})
+			obj.AddComponent(&FakeReaderComponent{Gauge: gauge})
+			runtime.Root().AddObject(obj)
+		}
+
+		// Changes to their own positions do not conflict with reading the positions of others
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(gauge.Highest > 1)
+	})
+}
+
+func TestDebugAccess(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{DebugAccess: true})
+		target := component.NewObject("Target")
+		target.AddComponent(&FakePosition{})
+		runtime.Root().AddObject(target)
+
+		spy := component.NewObject("Spy")
+		undeclared := &FakeSpyComponent{Target: target}
+		spy.AddComponent(undeclared)
+		runtime.Root().AddObject(spy)
+
+		watcher := component.NewObject("Watcher")
+		watcher.AddComponent(&FakeSpyComponent{Target: target, Declared: true})
+		runtime.Root().AddObject(watcher)
+
+		runtime.Update(1)
+		violations := runtime.AccessViolations()
+		T.Assert(len(violations) == 1)
+		T.Assert(violations[0].Component == undeclared)
+		T.Assert(violations[0].Object == spy)
+		T.Assert(violations[0].Target == target)
+		T.Assert(violations[0].Type == reflect.TypeOf(&FakePosition{}))
+
+		// Without debugging nothing is recorded
+		quiet := component.NewRuntime(component.Config{})
+		T.Assert(quiet.AccessViolations() == nil)
+	})
+}
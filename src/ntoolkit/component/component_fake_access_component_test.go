@@ -0,0 +1,92 @@
+package component_test
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"ntoolkit/component"
+)
+
+// FakeScoreBoard is a resource shared by FakeScoreComponents.
+type FakeScoreBoard struct {
+	Score    int
+	inside   int32
+	Overlaps int32
+}
+
+// FakeScoreComponent adds to a shared score board, and declares that it writes it.
+type FakeScoreComponent struct {
+	Board *FakeScoreBoard
+}
+
+func (fake *FakeScoreComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeScoreComponent) Access() component.ComponentAccess {
+	return component.ComponentAccess{WriteResources: []string{"score"}}
+}
+
+func (fake *FakeScoreComponent) Update(_ *component.Context) {
+	if atomic.AddInt32(&fake.Board.inside, 1) > 1 {
+		atomic.AddInt32(&fake.Board.Overlaps, 1)
+	}
+	time.Sleep(100 * time.Microsecond)
+	fake.Board.Score += 1
+	atomic.AddInt32(&fake.Board.inside, -1)
+}
+
+// FakeSpyComponent reads the position of another object.
+type FakeSpyComponent struct {
+	Target   *component.Object
+	Declared bool
+}
+
+func (fake *FakeSpyComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeSpyComponent) Access() component.ComponentAccess {
+	if fake.Declared {
+		return component.ComponentAccess{Reads: []reflect.Type{reflect.TypeOf(&FakePosition{})}}
+	}
+	return component.ComponentAccess{}
+}
+
+func (fake *FakeSpyComponent) Update(context *component.Context) {
+	component.GetComponent[*FakeSpyComponent](context.Object)
+	component.GetComponent[*FakePosition](fake.Target)
+}
+
+// FakeConcurrency counts how many FakeReaderComponents are updating at the same time.
+type FakeConcurrency struct {
+	inside  int32
+	Highest int32
+}
+
+// FakeReaderComponent declares that it reads positions on other objects, and records how many
+// readers are updating at once.
+type FakeReaderComponent struct {
+	Gauge *FakeConcurrency
+}
+
+func (fake *FakeReaderComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeReaderComponent) Access() component.ComponentAccess {
+	return component.ComponentAccess{Reads: []reflect.Type{reflect.TypeOf(&FakePosition{})}}
+}
+
+func (fake *FakeReaderComponent) Update(_ *component.Context) {
+	inside := atomic.AddInt32(&fake.Gauge.inside, 1)
+	for {
+		highest := atomic.LoadInt32(&fake.Gauge.Highest)
+		if inside <= highest || atomic.CompareAndSwapInt32(&fake.Gauge.Highest, highest, inside) {
+			break
+		}
+	}
+	time.Sleep(200 * time.Microsecond)
+	atomic.AddInt32(&fake.Gauge.inside, -1)
+}
@@ -0,0 +1,23 @@
+package component_test
+
+import (
+	"reflect"
+)
+
+// FakePosition is a plain data component.
+type FakePosition struct {
+	X, Y float32
+}
+
+func (fake *FakePosition) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+// FakeVelocity is a plain data component.
+type FakeVelocity struct {
+	X, Y float32
+}
+
+func (fake *FakeVelocity) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
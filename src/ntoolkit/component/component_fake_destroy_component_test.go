@@ -0,0 +1,26 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+// FakeDestroyComponent counts the number of times it is destroyed, and can fail when it is.
+type FakeDestroyComponent struct {
+	Destroys int
+	Fail     bool
+}
+
+func (fake *FakeDestroyComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeDestroyComponent) OnDestroy(context *component.Context) error {
+	fake.Destroys += 1
+	if fake.Fail {
+		return errors.Fail(component.ErrBadValue{}, nil, "Failed to release "+context.Object.Name())
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeEnableComponent counts the number of times it is enabled, disabled and updated.
+type FakeEnableComponent struct {
+	Enables  int
+	Disables int
+	Updates  int
+}
+
+func (fake *FakeEnableComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeEnableComponent) New() component.Component {
+	return &FakeEnableComponent{}
+}
+
+func (fake *FakeEnableComponent) OnEnable(_ *component.Context) {
+	fake.Enables += 1
+}
+
+func (fake *FakeEnableComponent) OnDisable(_ *component.Context) {
+	fake.Disables += 1
+}
+
+func (fake *FakeEnableComponent) Update(_ *component.Context) {
+	fake.Updates += 1
+}
@@ -0,0 +1,36 @@
+package component_test
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"ntoolkit/component"
+)
+
+// FakeHangComponent blocks in its first Start or Update, as given by Hang, until Release is closed.
+type FakeHangComponent struct {
+	Hang    string
+	Release chan struct{}
+	Starts  atomic.Int32
+	Updates atomic.Int32
+}
+
+func NewFakeHangComponent(hang string) *FakeHangComponent {
+	return &FakeHangComponent{Hang: hang, Release: make(chan struct{})}
+}
+
+func (fake *FakeHangComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeHangComponent) Start(_ *component.Context) {
+	if fake.Starts.Add(1) == 1 && fake.Hang == "Start" {
+		<-fake.Release
+	}
+}
+
+func (fake *FakeHangComponent) Update(_ *component.Context) {
+	if fake.Updates.Add(1) == 1 && fake.Hang == "Update" {
+		<-fake.Release
+	}
+}
@@ -0,0 +1,18 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeLogComponent logs a message every update.
+type FakeLogComponent struct{}
+
+func (fake *FakeLogComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeLogComponent) Update(context *component.Context) {
+	context.Logger.Info("Updated", "step", context.DeltaTime)
+}
@@ -0,0 +1,37 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeRequiresComponent only works with a FakeComponent on the same object.
+type FakeRequiresComponent struct{}
+
+func (fake *FakeRequiresComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeRequiresComponent) New() component.Component {
+	return &FakeRequiresComponent{}
+}
+
+func (fake *FakeRequiresComponent) Requires() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf(&FakeComponent{})}
+}
+
+// FakeRequiresUpdateComponent requires any component that implements Update.
+type FakeRequiresUpdateComponent struct{}
+
+func (fake *FakeRequiresUpdateComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeRequiresUpdateComponent) New() component.Component {
+	return &FakeRequiresUpdateComponent{}
+}
+
+func (fake *FakeRequiresUpdateComponent) Requires() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*component.Update)(nil)).Elem()}
+}
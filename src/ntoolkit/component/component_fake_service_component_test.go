@@ -0,0 +1,49 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeRandom is a service that returns numbers.
+type FakeRandom interface {
+	Next() int
+}
+
+// FakeFixedRandom always returns the same number.
+type FakeFixedRandom struct {
+	Value int
+}
+
+func (fake *FakeFixedRandom) Next() int {
+	return fake.Value
+}
+
+// FakeSettings is an optional service.
+type FakeSettings struct {
+	Difficulty string
+}
+
+// FakeServiceComponent has services injected into it.
+type FakeServiceComponent struct {
+	Random   FakeRandom    `service:""`
+	Settings *FakeSettings `service:"optional"`
+	Rolled   int
+	Looked   int
+}
+
+func (fake *FakeServiceComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeServiceComponent) New() component.Component {
+	return &FakeServiceComponent{}
+}
+
+func (fake *FakeServiceComponent) Update(context *component.Context) {
+	fake.Rolled = fake.Random.Next()
+	if random, err := component.Service[FakeRandom](context); err == nil {
+		fake.Looked = random.Next()
+	}
+}
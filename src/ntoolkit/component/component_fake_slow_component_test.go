@@ -0,0 +1,21 @@
+package component_test
+
+import (
+	"reflect"
+	"time"
+
+	"ntoolkit/component"
+)
+
+// FakeSlowComponent sleeps for Delay every update.
+type FakeSlowComponent struct {
+	Delay time.Duration
+}
+
+func (fake *FakeSlowComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeSlowComponent) Update(context *component.Context) {
+	time.Sleep(fake.Delay)
+}
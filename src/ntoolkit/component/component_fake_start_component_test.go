@@ -0,0 +1,40 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeStartComponent counts the number of times it is started and updated.
+type FakeStartComponent struct {
+	Starts  int
+	Updates int
+}
+
+func (fake *FakeStartComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeStartComponent) New() component.Component {
+	return &FakeStartComponent{}
+}
+
+func (fake *FakeStartComponent) Start(_ *component.Context) {
+	fake.Starts += 1
+}
+
+func (fake *FakeStartComponent) Update(_ *component.Context) {
+	fake.Updates += 1
+}
+
+func (fake *FakeStartComponent) Serialize() (interface{}, error) {
+	return component.SerializeState(fake)
+}
+
+func (fake *FakeStartComponent) Deserialize(raw interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	return component.DeserializeState(fake, raw)
+}
@@ -0,0 +1,59 @@
+package component_test
+
+import (
+	"reflect"
+	"sync"
+
+	"ntoolkit/component"
+)
+
+// FakeMoveSystem adds the velocity of each object to its position.
+type FakeMoveSystem struct {
+	Runs int
+	Log  *FakeSystemLog
+}
+
+func (fake *FakeMoveSystem) Access() component.SystemAccess {
+	return component.SystemAccess{
+		Reads:  []reflect.Type{reflect.TypeOf(&FakeVelocity{})},
+		Writes: []reflect.Type{reflect.TypeOf(&FakePosition{})}}
+}
+
+func (fake *FakeMoveSystem) Run(context *component.SystemContext) {
+	fake.Runs += 1
+	fake.Log.Add("move")
+	for _, obj := range context.Objects {
+		position, _ := component.GetComponent[*FakePosition](obj)
+		velocity, _ := component.GetComponent[*FakeVelocity](obj)
+		position.X += velocity.X * context.DeltaTime
+		position.Y += velocity.Y * context.DeltaTime
+	}
+}
+
+// FakeCountSystem counts the objects with a position each frame.
+type FakeCountSystem struct {
+	Name  string
+	Count int
+	Log   *FakeSystemLog
+}
+
+func (fake *FakeCountSystem) Access() component.SystemAccess {
+	return component.SystemAccess{Reads: []reflect.Type{reflect.TypeOf(&FakePosition{})}}
+}
+
+func (fake *FakeCountSystem) Run(context *component.SystemContext) {
+	fake.Log.Add(fake.Name)
+	fake.Count = len(context.Objects)
+}
+
+// FakeSystemLog records the order systems run in.
+type FakeSystemLog struct {
+	Entries []string
+	lock    sync.Mutex
+}
+
+func (log *FakeSystemLog) Add(entry string) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	log.Entries = append(log.Entries, entry)
+}
@@ -0,0 +1,24 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeTimeComponent records the time steps it is updated with.
+type FakeTimeComponent struct {
+	Updates  int
+	Delta    float32
+	Unscaled float32
+}
+
+func (fake *FakeTimeComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeTimeComponent) Update(context *component.Context) {
+	fake.Updates += 1
+	fake.Delta = context.DeltaTime
+	fake.Unscaled = context.UnscaledDeltaTime
+}
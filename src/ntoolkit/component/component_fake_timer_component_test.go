@@ -0,0 +1,63 @@
+package component_test
+
+import (
+	"reflect"
+
+	"ntoolkit/component"
+)
+
+// FakeTimerComponent schedules timers when it starts.
+type FakeTimerComponent struct {
+	Fired int
+	Ticks int
+	Every *component.Timer
+}
+
+func (fake *FakeTimerComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeTimerComponent) Start(context *component.Context) {
+	context.After(2, func(_ *component.Context) {
+		fake.Fired += 1
+	})
+	fake.Every = context.Every(1, func(_ *component.Context) {
+		fake.Ticks += 1
+	})
+}
+
+// FakeCoroutineComponent runs a coroutine when it starts, recording how far it got.
+type FakeCoroutineComponent struct {
+	Steps     int
+	Panic     bool // Panic after the first step
+	Cancelled bool
+	Done      chan bool
+	Routine   *component.Coroutine
+}
+
+func (fake *FakeCoroutineComponent) Type() reflect.Type {
+	return reflect.TypeOf(fake)
+}
+
+func (fake *FakeCoroutineComponent) Start(context *component.Context) {
+	fake.Done = make(chan bool, 1)
+	fake.Routine = context.Go(func(co *component.Coroutine) {
+		fake.Steps = 1
+		if fake.Panic {
+			panic("Oh no")
+		}
+		if !co.Wait(2) {
+			fake.Cancelled = true
+			fake.Done <- true
+			return
+		}
+		for fake.Steps < 10 {
+			fake.Steps += 1
+			if !co.Yield() {
+				fake.Cancelled = true
+				break
+			}
+		}
+		fake.Done <- true
+	})
+}
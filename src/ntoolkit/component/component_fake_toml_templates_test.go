@@ -0,0 +1,57 @@
+package component_test
+
+var objectTemplateSimpleToml string = `
+# A single object with two components
+Name = "Sample Object"
+Objects = []
+
+[[Components]]
+Type = "*ntoolkit/component_test.FakeComponent"
+
+[[Components]]
+Type = "*ntoolkit/component_test.FakeConfiguredComponent"
+Data.Items = [
+	{ Id = "1", Count = 1 },
+	{ Id = "2", Count = 2 },
+	{ Id = "3", Count = 3 },
+]
+`
+
+var objectTemplateNestedToml string = `
+Name = "Sample Object"
+
+[[Components]]
+Type = '*ntoolkit/component_test.FakeComponent'
+
+[[Objects]]
+Name = "N/A"
+Objects = []
+
+[[Objects.Components]]
+Type = '*ntoolkit/component_test.FakeComponent'
+
+[[Objects]]
+Name = "One"
+
+[[Objects.Objects]]
+Name = "Two"
+Objects = []
+
+[[Objects.Objects.Components]]
+Type = '*ntoolkit/component_test.FakeComponent'
+
+[[Objects.Objects.Components]]
+Type = '*ntoolkit/component_test.FakeConfiguredComponent'
+
+[[Objects.Objects.Components.Data.Items]]
+Id = "1"
+Count = 1
+
+[[Objects.Objects.Components.Data.Items]]
+Id = "2"
+Count = 2
+
+[[Objects.Objects.Components.Data.Items]]
+Id = "3"
+Count = 3
+`
@@ -0,0 +1,42 @@
+package component_test
+
+var objectTemplateSimpleYaml string = `
+# A single object with two components
+Name: Sample Object
+Components:
+  - Type: "*ntoolkit/component_test.FakeComponent"
+  - Type: "*ntoolkit/component_test.FakeConfiguredComponent"
+    Data:
+      Items:
+        - {Id: "1", Count: 1}
+        - {Id: "2", Count: 2}
+        - Id: "3"
+          Count: 3
+Objects: []
+`
+
+var objectTemplateNestedYaml string = `
+Name: Sample Object
+Components:
+- Type: '*ntoolkit/component_test.FakeComponent'
+Objects:
+- Name: N/A
+  Components:
+  - Type: '*ntoolkit/component_test.FakeComponent'
+  Objects: []
+- Name: One
+  Objects:
+  - Name: Two
+    Components:
+    - Type: '*ntoolkit/component_test.FakeComponent'
+    - Type: '*ntoolkit/component_test.FakeConfiguredComponent'
+      Data:
+        Items:
+        - Id: "1"
+          Count: 1
+        - Id: "2"
+          Count: 2
+        - Id: "3"
+          Count: 3
+    Objects: []
+`
@@ -0,0 +1,111 @@
+package component
+
+import (
+	"fmt"
+	"reflect"
+
+	"ntoolkit/errors"
+)
+
+// GetComponent returns the first component of type T on the object.
+// T is usually a pointer type, eg. GetComponent[*Health](obj), but may also be an interface that
+// components implement.
+func GetComponent[T Component](o *Object) (T, bool) {
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if component, ok := components[i].Component.(T); ok {
+			checkComponentAccess[T](o)
+			return component, true
+		}
+	}
+	var none T
+	return none, false
+}
+
+// GetComponents returns every component of type T on the object.
+func GetComponents[T Component](o *Object) []T {
+	rtn := make([]T, 0)
+	for component := range AllComponents[T](o) {
+		rtn = append(rtn, component)
+	}
+	return rtn
+}
+
+// GetComponentsInChildren returns every component of type T in the children of the object, in
+// the same order as Object.GetComponentsInChildren.
+func GetComponentsInChildren[T Component](o *Object) []T {
+	rtn := make([]T, 0)
+	for component := range AllComponentsInChildren[T](o) {
+		rtn = append(rtn, component)
+	}
+	return rtn
+}
+
+// RequireComponent returns the first component of type T on the object, or an ErrNoMatch if there
+// is none.
+func RequireComponent[T Component](o *Object) (T, error) {
+	component, ok := GetComponent[T](o)
+	if !ok {
+		return component, errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for component %s on object '%s'", typeName(reflect.TypeOf((*T)(nil)).Elem()), o.name))
+	}
+	return component, nil
+}
+
+// AddComponent creates a new component of type T, attaches it to the object and returns it.
+// T must be a pointer type; the component is a pointer to a new zero value.
+func AddComponent[T Component](o *Object) (T, error) {
+	var rtn T
+	componentType := reflect.TypeOf((*T)(nil)).Elem()
+	if componentType.Kind() != reflect.Ptr {
+		return rtn, errors.Fail(ErrNotSupported{}, nil, fmt.Sprintf("Cannot create component %s; it is not a pointer type", typeName(componentType)))
+	}
+	rtn = reflect.New(componentType.Elem()).Interface().(T)
+	if err := o.AddComponent(rtn); err != nil {
+		var none T
+		return none, err
+	}
+	return rtn, nil
+}
+
+// AllComponents returns an iterator over the components of type T on the object, for use with range.
+func AllComponents[T Component](o *Object) func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		components := o.components
+		for i := 0; i < len(components); i++ {
+			if component, ok := components[i].Component.(T); ok {
+				checkComponentAccess[T](o)
+				if !yield(component) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllComponentsInChildren returns an iterator over the components of type T in the children of the
+// object, breadth first, for use with range.
+func AllComponentsInChildren[T Component](o *Object) func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		queue := append([]*Object{}, o.children...)
+		for i := 0; i < len(queue); i++ {
+			components := queue[i].components
+			for j := 0; j < len(components); j++ {
+				if component, ok := components[j].Component.(T); ok {
+					checkComponentAccess[T](queue[i])
+					if !yield(component) {
+						return
+					}
+				}
+			}
+			queue = append(queue, queue[i].children...)
+		}
+	}
+}
+
+// checkComponentAccess records an undeclared access to components of type T on the object, if the runtime is
+// debugging access; see Object.checkAccess.
+func checkComponentAccess[T Component](o *Object) {
+	if runtime := o.Runtime(); runtime != nil && runtime.accessTracker != nil {
+		o.checkAccess(componentType[T]())
+	}
+}
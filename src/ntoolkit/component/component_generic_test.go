@@ -0,0 +1,83 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestGenericGetComponent(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		obj := component.NewObject("A")
+		_, ok := component.GetComponent[*FakeComponent](obj)
+		T.Assert(!ok)
+
+		_, err := component.RequireComponent[*FakeComponent](obj)
+		T.Assert(errors.Is(err, component.ErrNoMatch{}))
+
+		first := &FakeComponent{Id: "1"}
+		obj.AddComponent(&FakeConfiguredComponent{})
+		obj.AddComponent(first)
+		obj.AddComponent(&FakeComponent{Id: "2"})
+
+		found, ok := component.GetComponent[*FakeComponent](obj)
+		T.Assert(ok)
+		T.Assert(found == first)
+
+		found, err = component.RequireComponent[*FakeComponent](obj)
+		T.Assert(err == nil)
+		T.Assert(found == first)
+
+		all := component.GetComponents[*FakeComponent](obj)
+		T.Assert(len(all) == 2)
+		T.Assert(all[1].Id == "2")
+
+		count := 0
+		for range component.AllComponents[*FakeComponent](obj) {
+			count += 1
+			break
+		}
+		T.Assert(count == 1)
+	})
+}
+
+func TestGenericGetComponentsInChildren(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := component.NewObject("A")
+		root.AddComponent(&FakeComponent{Id: "root"})
+		b := component.NewObject("B")
+		c := component.NewObject("C")
+		root.AddObject(b)
+		b.AddObject(c)
+		b.AddComponent(&FakeComponent{Id: "b"})
+		c.AddComponent(&FakeComponent{Id: "c"})
+
+		all := component.GetComponentsInChildren[*FakeComponent](root)
+		T.Assert(len(all) == 2)
+		T.Assert(all[0].Id == "b")
+		T.Assert(all[1].Id == "c")
+
+		ids := ""
+		for fake := range component.AllComponentsInChildren[*FakeComponent](root) {
+			ids += fake.Id
+		}
+		T.Assert(ids == "bc")
+	})
+}
+
+func TestGenericAddComponent(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		obj := component.NewObject("A")
+		added, err := component.AddComponent[*FakeComponent](obj)
+		T.Assert(err == nil)
+		T.Assert(added != nil)
+
+		found, ok := component.GetComponent[*FakeComponent](obj)
+		T.Assert(ok)
+		T.Assert(found == added)
+
+		_, err = component.AddComponent[component.Component](obj)
+		T.Assert(errors.Is(err, component.ErrNotSupported{}))
+	})
+}
@@ -0,0 +1,118 @@
+package component
+
+import (
+	"reflect"
+
+	"ntoolkit/iter"
+)
+
+// componentIndex maps each component type to the objects in a runtime that have it, and how many
+// components of that type each object has.
+type componentIndex map[reflect.Type]map[*Object]int
+
+// indexComponentType returns a copy of the object's component type lookup with a component added or
+// removed. The lookup is replaced rather than modified so it can be read without locking.
+func indexComponentType(types map[reflect.Type][]*componentInfo, info *componentInfo, add bool) map[reflect.Type][]*componentInfo {
+	rtn := make(map[reflect.Type][]*componentInfo, len(types)+1)
+	for T, components := range types {
+		rtn[T] = components
+	}
+	existing := rtn[info.Type]
+	if add {
+		rtn[info.Type] = append(append(make([]*componentInfo, 0, len(existing)+1), existing...), info)
+		return rtn
+	}
+	components := make([]*componentInfo, 0, len(existing))
+	for i := 0; i < len(existing); i++ {
+		if existing[i] != info {
+			components = append(components, existing[i])
+		}
+	}
+	if len(components) == 0 {
+		delete(rtn, info.Type)
+	} else {
+		rtn[info.Type] = components
+	}
+	return rtn
+}
+
+// indexComponent records that a component of type T was added to or removed from an object
+func (runtime *Runtime) indexComponent(object *Object, T reflect.Type, add bool) {
+	runtime.indexLock.Lock()
+	defer runtime.indexLock.Unlock()
+	runtime.updateIndex(object, T, add)
+	if runtime.archetypes != nil {
+		runtime.archetypes.place(object)
+	}
+}
+
+// updateIndex adjusts the count for an object and a type; the index lock must be held.
+func (runtime *Runtime) updateIndex(object *Object, T reflect.Type, add bool) {
+	objects := runtime.index[T]
+	if add {
+		if objects == nil {
+			objects = make(map[*Object]int)
+			runtime.index[T] = objects
+		}
+		objects[object] += 1
+		return
+	}
+	if objects[object] <= 1 {
+		delete(objects, object)
+		if len(objects) == 0 {
+			delete(runtime.index, T)
+		}
+	} else {
+		objects[object] -= 1
+	}
+}
+
+// indexObjects adds or removes the components of an object and all of its children
+func (runtime *Runtime) indexObjects(object *Object, add bool) {
+	runtime.indexLock.Lock()
+	defer runtime.indexLock.Unlock()
+	runtime.indexTree(object, add)
+}
+
+// indexTree adds or removes the components of a subtree; the index lock must be held.
+func (runtime *Runtime) indexTree(object *Object, add bool) {
+	components := object.components
+	for i := 0; i < len(components); i++ {
+		runtime.updateIndex(object, components[i].Type, add)
+	}
+	if runtime.archetypes != nil {
+		if add {
+			runtime.archetypes.place(object)
+		} else {
+			runtime.archetypes.remove(object)
+		}
+	}
+	children := object.children
+	for i := 0; i < len(children); i++ {
+		runtime.indexTree(children[i], add)
+	}
+}
+
+// rebuildIndex discards the index and rebuilds it from the root object
+func (runtime *Runtime) rebuildIndex() {
+	runtime.indexLock.Lock()
+	defer runtime.indexLock.Unlock()
+	runtime.index = make(componentIndex)
+	if runtime.archetypes != nil {
+		runtime.archetypes = newArchetypeStore()
+	}
+	runtime.indexTree(runtime.root, true)
+}
+
+// ObjectsWithComponent returns an iterator of every object in the runtime, including the root, that
+// has a component of the given type. The objects are found directly from an index, so this does not
+// walk the object tree; they are returned in no particular order.
+func (runtime *Runtime) ObjectsWithComponent(T reflect.Type) iter.Iter {
+	runtime.indexLock.Lock()
+	objects := make([]*Object, 0, len(runtime.index[T]))
+	for object := range runtime.index[T] {
+		objects = append(objects, object)
+	}
+	runtime.indexLock.Unlock()
+	return fromObjectList(objects, nil)
+}
@@ -0,0 +1,90 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/iter"
+	"reflect"
+	"testing"
+)
+
+func objectsWith(T *assert.T, runtime *component.Runtime, value component.Component) map[*component.Object]bool {
+	values, err := iter.Collect(runtime.ObjectsWithComponent(reflect.TypeOf(value)))
+	T.Assert(err == nil)
+	rtn := make(map[*component.Object]bool)
+	for i := 0; i < len(values); i++ {
+		rtn[values[i].(*component.Object)] = true
+	}
+	return rtn
+}
+
+func TestObjectsWithComponent(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		a := component.NewObject("A")
+		b := component.NewObject("B")
+		c := component.NewObject("C")
+		a.AddComponent(&FakeComponent{})
+		c.AddComponent(&FakeComponent{})
+		c.AddComponent(&FakeComponent{})
+		b.AddObject(c)
+
+		T.Assert(len(objectsWith(T, runtime, &FakeComponent{})) == 0)
+
+		runtime.Root().AddObject(a)
+		runtime.Root().AddObject(b)
+		found := objectsWith(T, runtime, &FakeComponent{})
+		T.Assert(len(found) == 2)
+		T.Assert(found[a] && found[c])
+
+		// Components added to objects already in the runtime
+		extra := &FakeComponent{}
+		b.AddComponent(extra)
+		T.Assert(objectsWith(T, runtime, &FakeComponent{})[b])
+		T.Assert(b.RemoveComponent(extra) == nil)
+		T.Assert(!objectsWith(T, runtime, &FakeComponent{})[b])
+
+		// An object with several components of a type stays until the last is removed
+		fakes := component.GetComponents[*FakeComponent](c)
+		T.Assert(c.RemoveComponent(fakes[0]) == nil)
+		T.Assert(objectsWith(T, runtime, &FakeComponent{})[c])
+
+		// Moving inside the runtime keeps the index, removing the subtree clears it
+		T.Assert(a.AddObject(b) == nil)
+		T.Assert(objectsWith(T, runtime, &FakeComponent{})[c])
+		T.Assert(a.RemoveObject(b) == nil)
+		T.Assert(!objectsWith(T, runtime, &FakeComponent{})[c])
+
+		c.AddComponent(&FakeComponent{})
+		T.Assert(!objectsWith(T, runtime, &FakeComponent{})[c])
+		T.Assert(len(component.GetComponents[*FakeComponent](c)) == 2)
+
+		count, err := iter.Count(c.GetComponents(reflect.TypeOf(&FakeComponent{})))
+		T.Assert(err == nil)
+		T.Assert(count == 2)
+	})
+}
+
+func TestObjectsWithComponentAfterRestore(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		factory.Register(&FakeStartComponent{})
+		runtime := component.NewRuntime(component.Config{Factory: factory})
+
+		before := component.NewObject("Before")
+		before.AddComponent(&FakeStartComponent{})
+		runtime.Root().AddObject(before)
+
+		snapshot, err := runtime.Snapshot()
+		T.Assert(err == nil)
+		T.Assert(runtime.Restore(snapshot) == nil)
+
+		found := objectsWith(T, runtime, &FakeStartComponent{})
+		T.Assert(len(found) == 1)
+		T.Assert(!found[before])
+
+		restored, err := runtime.Root().GetObject("Before")
+		T.Assert(err == nil)
+		T.Assert(found[restored])
+	})
+}
@@ -1,6 +1,9 @@
 package component
 
-import "reflect"
+import (
+	"reflect"
+	"sync/atomic"
+)
 
 type componentInfo struct {
 	Type      reflect.Type // The components type, cached
@@ -10,6 +13,14 @@ type componentInfo struct {
 	Start     Start        // Start interface for component, if any
 	Update    Update       // Update interface for component, if any
 	Persist   Persist      // Persist interface for component, if any
+	OnEnable  OnEnable     // OnEnable interface for component, if any
+	OnDisable OnDisable    // OnDisable interface for component, if any
+	OnDestroy OnDestroy    // OnDestroy interface for component, if any
+	Access    Access       // Access interface for component, if any
+	Disabled  bool         // The component is disabled and is not started or updated
+
+	logger  atomic.Pointer[cachedLogger] // The annotated logger for the component; see componentLogger
+	faulted atomic.Int32                 // If the watchdog has faulted the component; see notFaulted
 }
 
 func newComponentInfo(cmp Component) *componentInfo {
@@ -29,20 +40,33 @@ func newComponentInfo(cmp Component) *componentInfo {
 	if rtn.Type.Implements(reflect.TypeOf((*Persist)(nil)).Elem()) {
 		rtn.Persist = rtn.Component.(Persist)
 	}
+	if rtn.Type.Implements(reflect.TypeOf((*OnEnable)(nil)).Elem()) {
+		rtn.OnEnable = rtn.Component.(OnEnable)
+	}
+	if rtn.Type.Implements(reflect.TypeOf((*OnDisable)(nil)).Elem()) {
+		rtn.OnDisable = rtn.Component.(OnDisable)
+	}
+	if rtn.Type.Implements(reflect.TypeOf((*OnDestroy)(nil)).Elem()) {
+		rtn.OnDestroy = rtn.Component.(OnDestroy)
+	}
+	if rtn.Type.Implements(reflect.TypeOf((*Access)(nil)).Elem()) {
+		rtn.Access = rtn.Component.(Access)
+	}
 	return rtn
 }
 
-// Update a single component
-func (info *componentInfo) updateComponent(step float32, runtime *Runtime, context *Context) {
-	if info.Active == 0 && info.Start != nil {
-		runtime.workers.Run(func() {
-			info.Start.Start(context)
-			info.Active += 1
-		})
+// Update a single component; returns false if it has nothing to start or update
+func (info *componentInfo) updateComponent(context *Context) bool {
+	if info.faulted.Load() != notFaulted {
+		return false
+	} else if info.Active == 0 && info.Start != nil {
+		info.Start.Start(context)
+		info.Active += 1
+		return true
 	} else if info.Update != nil {
-		runtime.workers.Run(func() {
-			info.Update.Update(context)
-			info.Active += 1
-		})
+		info.Update.Update(context)
+		info.Active += 1
+		return true
 	}
-}
\ No newline at end of file
+	return false
+}
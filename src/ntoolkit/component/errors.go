@@ -16,4 +16,25 @@ type ErrNoMatch struct{}
 type ErrBadObject struct{}
 
 // ErrNotSupported is raised when trying to perform an invalid operation that is not supported.
-type ErrNotSupported struct{}
\ No newline at end of file
+type ErrNotSupported struct{}
+
+// ErrMissingDependency is raised when a component requires another component that is missing and cannot be added.
+type ErrMissingDependency struct{}
+
+// ErrQueueFull is raised when a task cannot be scheduled because the task queue is full.
+type ErrQueueFull struct{}
+
+// ErrTaskFailed is raised when a scheduled task or a coroutine panics.
+type ErrTaskFailed struct{}
+
+// ErrClosed is raised when using a runtime that has been closed.
+type ErrClosed struct{}
+
+// ErrDestroyFailed is raised when destroy hooks fail while destroying objects or closing a runtime.
+type ErrDestroyFailed struct{}
+
+// ErrMissingService is raised when a service is not registered with a runtime.
+type ErrMissingService struct{}
+
+// ErrAccessConflict is raised when the declared access of a system conflicts with another system.
+type ErrAccessConflict struct{}
@@ -60,4 +60,15 @@ func (iterator *ObjectIter) nextObject() *Object {
 		iterator.err = errors.Fail(iter.ErrEndIteration{}, nil, "No more values")
 		return nil
 	}
-}
\ No newline at end of file
+}
+// fromObjectList returns a new iterator over a fixed list of objects, or one that only returns err
+func fromObjectList(objects []*Object, err error) *ObjectIter {
+	rtn := &ObjectIter{values: list.New(), err: err}
+	if err == nil && len(objects) == 0 {
+		rtn.err = errors.Fail(iter.ErrEndIteration{}, nil, "No more values")
+	}
+	for i := 0; i < len(objects); i++ {
+		rtn.values.PushBack(objects[i])
+	}
+	return rtn
+}
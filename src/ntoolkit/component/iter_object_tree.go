@@ -0,0 +1,129 @@
+package component
+
+import (
+	"fmt"
+
+	"ntoolkit/errors"
+	"ntoolkit/iter"
+)
+
+// TraversalOrder is the order a TreeIter visits objects in.
+type TraversalOrder int
+
+const (
+	PreOrder  TraversalOrder = iota // Parents before their children
+	PostOrder                       // Children before their parents
+)
+
+// TraversalOptions configures a depth first traversal of an object tree.
+type TraversalOptions struct {
+	Order       TraversalOrder
+	MaxDepth    int                       // The deepest level to visit; children are depth 1. Zero is no limit.
+	IncludeSelf bool                      // Visit the object the traversal starts from, at depth 0
+	Prune       func(node *TreeNode) bool // If set and it returns true, the object and its children are skipped
+}
+
+// TreeNode is a single object visited by a TreeIter.
+type TreeNode struct {
+	Object *Object
+	Depth  int      // The number of levels below the object the traversal started from
+	Path   []string // The names from the starting object down to this one, for FindObject
+}
+
+// TreeIter is a depth first iterator over an object tree.
+// Next returns a *TreeNode for each object, or the *Object itself for the iterators returned by
+// ObjectsDepthFirst and ObjectsPostOrder.
+type TreeIter struct {
+	options TraversalOptions
+	stack   []treeFrame
+	objects bool
+	err     error
+}
+
+// treeFrame is a pending object on the traversal stack
+type treeFrame struct {
+	node     *TreeNode
+	expanded bool // For post order, the children have already been pushed
+}
+
+// fromObjectTree returns a new depth first iterator from root
+func fromObjectTree(root *Object, options TraversalOptions, objects bool) *TreeIter {
+	rtn := &TreeIter{options: options, stack: make([]treeFrame, 0), objects: objects}
+	if root == nil {
+		rtn.err = errors.Fail(ErrNullValue{}, nil, "Invalid root object")
+		return rtn
+	}
+	start := &TreeNode{Object: root, Depth: 0, Path: []string{}}
+	if options.IncludeSelf {
+		if options.Prune == nil || !options.Prune(start) {
+			rtn.stack = append(rtn.stack, treeFrame{node: start})
+		}
+	} else {
+		rtn.pushChildren(start)
+	}
+	return rtn
+}
+
+// Next returns the next object in the traversal
+func (iterator *TreeIter) Next() (interface{}, error) {
+	node, err := iterator.NextNode()
+	if err != nil {
+		return nil, err
+	}
+	if iterator.objects {
+		return node.Object, nil
+	}
+	return node, nil
+}
+
+// NextNode returns the next object in the traversal with its depth and path
+func (iterator *TreeIter) NextNode() (*TreeNode, error) {
+	if iterator.err != nil {
+		return nil, iterator.err
+	}
+	for len(iterator.stack) > 0 {
+		top := len(iterator.stack) - 1
+		frame := iterator.stack[top]
+		if iterator.options.Order == PostOrder && !frame.expanded {
+			iterator.stack[top].expanded = true
+			iterator.pushChildren(frame.node)
+			continue
+		}
+		iterator.stack = iterator.stack[:top]
+		if iterator.options.Order == PreOrder {
+			iterator.pushChildren(frame.node)
+		}
+		return frame.node, nil
+	}
+	iterator.err = errors.Fail(iter.ErrEndIteration{}, nil, "No more values")
+	return nil, iterator.err
+}
+
+// pushChildren pushes the children of a node that are within the depth limit and not pruned, last
+// child first so the first child is visited first.
+func (iterator *TreeIter) pushChildren(parent *TreeNode) {
+	if iterator.options.MaxDepth > 0 && parent.Depth >= iterator.options.MaxDepth {
+		return
+	}
+	children := parent.Object.children
+	names := make(map[string]int, len(children))
+	nodes := make([]*TreeNode, 0, len(children))
+	for i := 0; i < len(children); i++ {
+		// Name duplicate siblings by index, so the path always finds the same object again
+		name := children[i].name
+		if count := names[children[i].name]; count > 0 {
+			name = fmt.Sprintf("%s[%d]", name, count)
+		}
+		names[children[i].name] += 1
+
+		path := make([]string, len(parent.Path), len(parent.Path)+1)
+		copy(path, parent.Path)
+		node := &TreeNode{Object: children[i], Depth: parent.Depth + 1, Path: append(path, name)}
+		if iterator.options.Prune == nil || !iterator.options.Prune(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		iterator.stack = append(iterator.stack, treeFrame{node: nodes[i]})
+	}
+}
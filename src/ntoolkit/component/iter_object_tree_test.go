@@ -0,0 +1,88 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/iter"
+	"strings"
+	"testing"
+)
+
+// treeFixture builds A(B(D, E), C(F)) and returns the root
+func treeFixture() *component.Object {
+	a := component.NewObject("A")
+	b := component.NewObject("B")
+	c := component.NewObject("C")
+	a.AddObject(b)
+	a.AddObject(c)
+	b.AddObject(component.NewObject("D"))
+	b.AddObject(component.NewObject("E"))
+	c.AddObject(component.NewObject("F"))
+	return a
+}
+
+func treeNames(T *assert.T, objects iter.Iter) string {
+	values, err := iter.Collect(objects)
+	T.Assert(err == nil)
+	names := make([]string, len(values))
+	for i := 0; i < len(values); i++ {
+		if node, ok := values[i].(*component.TreeNode); ok {
+			names[i] = node.Object.Name()
+		} else {
+			names[i] = values[i].(*component.Object).Name()
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func TestObjectsDepthFirst(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := treeFixture()
+		T.Assert(treeNames(T, root.ObjectsDepthFirst()) == "B,D,E,C,F")
+		T.Assert(treeNames(T, root.ObjectsPostOrder()) == "D,E,B,F,C")
+		T.Assert(treeNames(T, root.Traverse(component.TraversalOptions{Order: component.PostOrder, IncludeSelf: true})) == "D,E,B,F,C,A")
+
+		count, err := iter.Count(component.NewObject().ObjectsDepthFirst())
+		T.Assert(err == nil)
+		T.Assert(count == 0)
+	})
+}
+
+func TestTraverseDepthAndPrune(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := treeFixture()
+		T.Assert(treeNames(T, root.Traverse(component.TraversalOptions{MaxDepth: 1})) == "B,C")
+		T.Assert(treeNames(T, root.Traverse(component.TraversalOptions{MaxDepth: 1, IncludeSelf: true})) == "A,B,C")
+
+		pruned := root.Traverse(component.TraversalOptions{Prune: func(node *component.TreeNode) bool {
+			return node.Object.Name() == "B"
+		}})
+		T.Assert(treeNames(T, pruned) == "C,F")
+
+		pruned = root.Traverse(component.TraversalOptions{Order: component.PostOrder, Prune: func(node *component.TreeNode) bool {
+			return node.Depth > 1
+		}})
+		T.Assert(treeNames(T, pruned) == "B,C")
+	})
+}
+
+func TestTraverseNodes(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := treeFixture()
+		second := component.NewObject("B")
+		root.AddObject(second)
+		child := component.NewObject("G")
+		second.AddObject(child)
+
+		nodes := root.Traverse(component.TraversalOptions{})
+		for node, err := nodes.NextNode(); err == nil; node, err = nodes.NextNode() {
+			T.Assert(len(node.Path) == node.Depth)
+			found, err := root.FindObject(node.Path...)
+			T.Assert(err == nil)
+			T.Assert(found == node.Object)
+			if node.Object == child {
+				T.Assert(strings.Join(node.Path, "/") == "B[1]/G")
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package component
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// objectLogHandler annotates records with the object, and component if any, they were logged for.
+// The attributes are read when a record is handled, so they follow renames and moves.
+type objectLogHandler struct {
+	handler   slog.Handler
+	object    *Object
+	component reflect.Type
+}
+
+func (handler *objectLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return handler.handler.Enabled(ctx, level)
+}
+
+func (handler *objectLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(
+		slog.String("object_id", handler.object.id),
+		slog.String("object_path", handler.object.Path()))
+	if handler.component != nil {
+		record.AddAttrs(slog.String("component", typeName(handler.component)))
+	}
+	return handler.handler.Handle(ctx, record)
+}
+
+func (handler *objectLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &objectLogHandler{handler: handler.handler.WithAttrs(attrs), object: handler.object, component: handler.component}
+}
+
+func (handler *objectLogHandler) WithGroup(name string) slog.Handler {
+	return &objectLogHandler{handler: handler.handler.WithGroup(name), object: handler.object, component: handler.component}
+}
+
+// cachedLogger is an annotated logger, and the runtime logger it was made from. The handler and
+// logger are kept by value, so caching a logger is a single allocation.
+type cachedLogger struct {
+	base    *slog.Logger
+	handler objectLogHandler
+	logger  slog.Logger
+}
+
+// annotatedLogger returns a logger for an object or component, made from base and cached until the
+// base logger changes
+func annotatedLogger(cache *atomic.Pointer[cachedLogger], base *slog.Logger, object *Object, component reflect.Type) *slog.Logger {
+	if base == nil {
+		return nil
+	}
+	if cached := cache.Load(); cached != nil && cached.base == base {
+		return &cached.logger
+	}
+	cached := &cachedLogger{base: base, handler: objectLogHandler{handler: base.Handler(), object: object, component: component}}
+	cached.logger = *slog.New(&cached.handler)
+	cache.Store(cached)
+	return &cached.logger
+}
+
+// Path returns the names of the object and its parents, separated by '/', from the root of the
+// runtime, eg. "/World/Zone/Bullet". The root of a runtime is "/"; an object outside a runtime has
+// a path from its top most parent.
+func (o *Object) Path() string {
+	names := make([]string, 0)
+	for cursor := o; cursor != nil; cursor = cursor.parent {
+		if cursor.parent == nil && cursor.runtime != nil && cursor.runtime.root == cursor {
+			break
+		}
+		names = append(names, cursor.name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return "/" + strings.Join(names, "/")
+}
+
+// Logger returns the runtime logger, annotated with the id and path of the object, or nil if the
+// object is not in a runtime.
+func (o *Object) Logger() *slog.Logger {
+	runtime := o.Runtime()
+	if runtime == nil {
+		return nil
+	}
+	return annotatedLogger(&o.logger, runtime.logger, o, nil)
+}
+
+// componentLogger returns the runtime logger annotated with the object and the component type
+func (info *componentInfo) componentLogger(object *Object, runtime *Runtime) *slog.Logger {
+	if runtime == nil {
+		return nil
+	}
+	return annotatedLogger(&info.logger, runtime.logger, object, info.Type)
+}
@@ -0,0 +1,73 @@
+package component_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"strings"
+	"testing"
+)
+
+// logRecords parses the JSON log records written to a buffer
+func logRecords(output *bytes.Buffer) []map[string]interface{} {
+	records := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		record := make(map[string]interface{})
+		if json.Unmarshal([]byte(line), &record) == nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestComponentLogger(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		output := &bytes.Buffer{}
+		runtime := component.NewRuntime(component.Config{LogHandler: slog.NewJSONHandler(output, nil)})
+		world := component.NewObject("World")
+		obj := component.NewObject("Bard")
+		obj.AddComponent(&FakeLogComponent{})
+		world.AddObject(obj)
+		runtime.Root().AddObject(world)
+		T.Assert(obj.Path() == "/World/Bard")
+		T.Assert(runtime.Root().Path() == "/")
+
+		runtime.Update(1)
+		records := logRecords(output)
+		T.Assert(len(records) == 1)
+		T.Assert(records[0]["msg"] == "Updated")
+		T.Assert(records[0]["object_id"] == obj.ID())
+		T.Assert(records[0]["object_path"] == "/World/Bard")
+		T.Assert(records[0]["component"] == "*ntoolkit/component_test.FakeLogComponent")
+
+		// The path follows renames
+		output.Reset()
+		obj.Rename("Minstrel")
+		runtime.Update(1)
+		records = logRecords(output)
+		T.Assert(len(records) == 1)
+		T.Assert(records[0]["object_path"] == "/World/Minstrel")
+	})
+}
+
+func TestRuntimeEventLogging(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		output := &bytes.Buffer{}
+		runtime := component.NewRuntime(component.Config{
+			Logger:    slog.New(slog.NewJSONHandler(output, nil)),
+			SlowFrame: 1})
+		runtime.ScheduleTask(func() error {
+			panic("Oh no")
+		})
+		runtime.Update(1)
+
+		records := logRecords(output)
+		T.Assert(len(records) == 2)
+		T.Assert(records[0]["level"] == "ERROR")
+		T.Assert(records[0]["msg"] == "Scheduled task panicked")
+		T.Assert(records[1]["level"] == "WARN")
+		T.Assert(records[1]["msg"] == "Slow frame")
+	})
+}
@@ -2,11 +2,13 @@ package component
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"reflect"
 	_ "runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"ntoolkit/errors"
 	"ntoolkit/iter"
@@ -16,10 +18,16 @@ import (
 type Object struct {
 	id         string
 	name       string
+	tags       []string // The tags on this object, in the order they were added
+	inactive   bool     // The object and its children are not updated
+	timeScale  float32  // The scale of time for this object and its children; see SetTimeScale
 	runtime    *Runtime
-	components []*componentInfo // The set of components attached to this node
-	children   []*Object        // The set of child objects attached to this node
+	components []*componentInfo                  // The set of components attached to this node
+	types      map[reflect.Type][]*componentInfo // The components attached to this node, by type
+	children   []*Object                         // The set of child objects attached to this node
+	timers     []scheduled                       // The timers and coroutines running on this node
 	parent     *Object
+	logger     atomic.Pointer[cachedLogger] // The annotated logger for this node; see Logger()
 	writeLock  *sync.Mutex
 	locked     bool
 }
@@ -33,61 +41,201 @@ func NewObject(names ...string) *Object {
 	return &Object{
 		id:         makeObjectId(),
 		name:       name,
+		timeScale:  1,
 		runtime:    nil,
 		components: make([]*componentInfo, 0),
+		types:      make(map[reflect.Type][]*componentInfo),
 		children:   make([]*Object, 0),
 		writeLock:  &sync.Mutex{}}
 }
 
-// Add a behaviour to a node
-func (o *Object) AddComponent(component Component) {
+// Add a behaviour to a node.
+// If the component implements Requires, any missing dependencies are first created by the providers
+// registered with the runtime's factory; if one cannot be created nothing is added, and an
+// ErrMissingDependency is returned. If the object is in a runtime, its services are injected into
+// the components first; see Services.
+func (o *Object) AddComponent(component Component) error {
+	var factory *ObjectFactory
+	var services *Services
+	if runtime := o.Runtime(); runtime != nil {
+		factory = runtime.factory
+		services = runtime.services
+	}
+	dependencies := make([]Component, 0)
+	if err := o.planDependencies(component, factory, map[reflect.Type]bool{component.Type(): true}, &dependencies); err != nil {
+		return err
+	}
+	for i := 0; i < len(dependencies); i++ {
+		if err := injectServices(dependencies[i], services); err != nil {
+			return err
+		}
+	}
+	if err := injectServices(component, services); err != nil {
+		return err
+	}
+	for i := 0; i < len(dependencies); i++ {
+		o.attachComponent(dependencies[i])
+	}
+	o.attachComponent(component)
+	return nil
+}
+
+// attachComponent adds a component without checking its dependencies
+func (o *Object) attachComponent(component Component) *componentInfo {
 	info := newComponentInfo(component)
 	o.WithLock(func() error {
 		o.components = append(o.components, info)
+		o.types = indexComponentType(o.types, info, true)
 		return nil
 	})
+	if runtime := o.Runtime(); runtime != nil {
+		runtime.indexComponent(o, info.Type, true)
+	}
 	if info.Attach != nil {
 		info.Attach.Attach(o)
 	}
+	return info
 }
 
-// Add a child object
+// Remove a behaviour from a node
+func (o *Object) RemoveComponent(component Component) error {
+	var removed *componentInfo
+	err := o.WithLock(func() error {
+		for i := 0; i < len(o.components); i++ {
+			if o.components[i].Component == component {
+				removed = o.components[i]
+				// Copy rather than modify in place, in case an update is iterating the old set
+				components := make([]*componentInfo, 0, len(o.components)-1)
+				components = append(components, o.components[:i]...)
+				o.components = append(components, o.components[i+1:]...)
+				o.types = indexComponentType(o.types, removed, false)
+				return nil
+			}
+		}
+		return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for component %s on object '%s'", typeName(component.Type()), o.name))
+	})
+	if err != nil {
+		return err
+	}
+	if runtime := o.Runtime(); runtime != nil {
+		runtime.indexComponent(o, removed.Type, false)
+	}
+	o.cancelTimers(func(task scheduled) bool { return task.owner() == component })
+	return nil
+}
+
+// Add a child object.
+// If the object is entering a runtime, the runtime services are injected into its components first.
+// The name policy is only applied once the object is known to be a valid child.
 func (o *Object) AddObject(object *Object) error {
+	return o.addObject(object, true)
+}
+
+// addObject adds a child object, applying the name policy of the runtime if names is set
+func (o *Object) addObject(object *Object, names bool) error {
+	if o == object || o.HasParent(object) {
+		return errors.Fail(ErrBadObject{}, nil, "Circular object references are not permitted")
+	}
+	if err := injectEntering(o, object); err != nil {
+		return err
+	}
+	if names {
+		if err := applyNamePolicy(o, object); err != nil {
+			return err
+		}
+	}
+	wasActive := object.ActiveInHierarchy()
 	object.Move(nil)
-	return o.WithLock(func() error {
-		if o == object || o.HasParent(object) {
-			return errors.Fail(ErrBadObject{}, nil, "Circular object references are not permitted")
-		} else {
-			// Move the object into the new parent 'o'; this will lock the child and the old parent.
-			object.Move(o)
+	err := o.WithLock(func() error {
+		// Move the object into the new parent 'o'; this will lock the child and the old parent.
+		object.Move(o)
 
-			// Now assign a new reference to this object
-			o.children = append(o.children, object)
+		// Now assign a new reference to this object
+		o.children = append(o.children, object)
+		return nil
+	})
+	if err == nil {
+		if runtime := o.Runtime(); runtime != nil {
+			runtime.indexObjects(object, true)
 		}
+		object.notifyActiveChange(wasActive)
+	}
+	return err
+}
+
+// InsertObject adds a child object at the given sibling index; an index equal to the number
+// of children appends the object.
+func (o *Object) InsertObject(object *Object, index int) error {
+	count := len(o.children)
+	if object.parent == o {
+		count -= 1
+	}
+	if index < 0 || index > count {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid sibling index %d for %d children", index, count))
+	}
+	if o == object || o.HasParent(object) {
+		return errors.Fail(ErrBadObject{}, nil, "Circular object references are not permitted")
+	}
+	if err := injectEntering(o, object); err != nil {
+		return err
+	}
+	if err := applyNamePolicy(o, object); err != nil {
+		return err
+	}
+	wasActive := object.ActiveInHierarchy()
+	object.Move(nil)
+	err := o.WithLock(func() error {
+		object.Move(o)
+		children := make([]*Object, 0, len(o.children)+1)
+		children = append(children, o.children[:index]...)
+		children = append(children, object)
+		o.children = append(children, o.children[index:]...)
 		return nil
 	})
+	if err == nil {
+		if runtime := o.Runtime(); runtime != nil {
+			runtime.indexObjects(object, true)
+		}
+		object.notifyActiveChange(wasActive)
+	}
+	return err
 }
 
-// Move the object into a new parent object, which may also be nil
+// Move the object into a new parent object, which may also be nil. Unlike RemoveObject, the
+// timers and coroutines of the object are kept.
 func (o *Object) Move(parent *Object) error {
 	oldParent := o.Parent()
 	if oldParent != nil {
-		if err := oldParent.RemoveObject(o); err != nil {
+		if err := oldParent.detach(o); err != nil {
 			return err
 		}
 	}
 	return o.WithLock(func() error {
 		o.parent = parent
-		o.runtime = parent.runtime // see Runtime()
+		o.runtime = nil
+		if parent != nil {
+			o.runtime = parent.runtime // see Runtime()
+		}
 		return nil
 	})
 }
 
-// Remove a child object
+// Remove a child object. The object is no longer updated, so the timers and coroutines of the object
+// and its children are cancelled; use Move to reparent an object and keep them.
 func (o *Object) RemoveObject(object *Object) error {
+	if err := o.detach(object); err != nil {
+		return err
+	}
+	object.cancelTimersInTree()
+	return nil
+}
+
+// detach removes a child object, leaving its timers and coroutines in place
+func (o *Object) detach(object *Object) error {
 	if o == object {
 		return errors.Fail(ErrBadObject{}, nil, "Cannot remove object from itself")
 	}
+	runtime := o.Runtime()
 	return o.WithLock(func() error {
 		offset := -1
 		for i := 0; i < len(o.children); i++ {
@@ -98,16 +246,29 @@ func (o *Object) RemoveObject(object *Object) error {
 		}
 		if offset >= 0 {
 			o.children = append(o.children[:offset], o.children[offset+1:]...)
+			if runtime != nil {
+				runtime.indexObjects(object, false)
+			}
 		}
 		object.WithLock(func() error {
 			object.parent = nil
 			object.runtime = nil
 			return nil
 		})
+		// The children may have cached the runtime the object has just left; see Runtime()
+		forgetRuntime(object.children)
 		return nil
 	})
 }
 
+// forgetRuntime clears the cached runtime of a set of objects and all their children
+func forgetRuntime(objects []*Object) {
+	for i := 0; i < len(objects); i++ {
+		objects[i].runtime = nil
+		forgetRuntime(objects[i].children)
+	}
+}
+
 // Check if an object has a parent
 func (o *Object) HasParent(object *Object) bool {
 	root := o
@@ -125,6 +286,68 @@ func (o *Object) Parent() *Object {
 	return o.parent
 }
 
+// SiblingIndex returns the index of this object among its siblings, or -1 if it has no parent.
+func (o *Object) SiblingIndex() int {
+	parent := o.parent
+	if parent == nil {
+		return -1
+	}
+	for i := 0; i < len(parent.children); i++ {
+		if parent.children[i] == o {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetSiblingIndex moves this object to the given index among its siblings.
+func (o *Object) SetSiblingIndex(index int) error {
+	parent := o.parent
+	if parent == nil {
+		return errors.Fail(ErrBadObject{}, nil, "Cannot set the sibling index of an object without a parent")
+	}
+	return parent.WithLock(func() error {
+		if index < 0 || index >= len(parent.children) {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid sibling index %d for %d children", index, len(parent.children)))
+		}
+		children := make([]*Object, 0, len(parent.children))
+		for i := 0; i < len(parent.children); i++ {
+			if parent.children[i] != o {
+				children = append(children, parent.children[i])
+			}
+		}
+		children = append(children[:index], append([]*Object{o}, children[index:]...)...)
+		parent.children = children
+		return nil
+	})
+}
+
+// SortChildren reorders the immediate children of this object; the sort is stable.
+func (o *Object) SortChildren(less func(a *Object, b *Object) bool) {
+	o.WithLock(func() error {
+		children := append([]*Object{}, o.children...)
+		sort.SliceStable(children, func(i, j int) bool {
+			return less(children[i], children[j])
+		})
+		o.children = children
+		return nil
+	})
+}
+
+// Child returns the immediate child object at the given index
+func (o *Object) Child(index int) (*Object, error) {
+	children := o.children
+	if index < 0 || index >= len(children) {
+		return nil, errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No child %d on parent '%s'", index, o.name))
+	}
+	return children[index], nil
+}
+
+// ChildCount returns the number of immediate child objects
+func (o *Object) ChildCount() int {
+	return len(o.children)
+}
+
 // Return the root object in the current object tree
 func (o *Object) Root() *Object {
 	i := o
@@ -148,9 +371,28 @@ func (o *Object) ObjectsInChildren() iter.Iter {
 	return fromObject(o, true)
 }
 
+// ObjectsDepthFirst returns an iterator of all the child objects on a game object, each object
+// before its children.
+func (o *Object) ObjectsDepthFirst() iter.Iter {
+	return fromObjectTree(o, TraversalOptions{Order: PreOrder}, true)
+}
+
+// ObjectsPostOrder returns an iterator of all the child objects on a game object, each object
+// after its children.
+func (o *Object) ObjectsPostOrder() iter.Iter {
+	return fromObjectTree(o, TraversalOptions{Order: PostOrder}, true)
+}
+
+// Traverse returns a depth first iterator of *TreeNode values for the objects below this one.
+func (o *Object) Traverse(options TraversalOptions) *TreeIter {
+	return fromObjectTree(o, options, false)
+}
+
 // GetComponents returns an iterator of all components matching the given type.
 func (o *Object) GetComponents(T reflect.Type) iter.Iter {
-	return fromComponentArray(&o.components, T)
+	o.checkAccess(T)
+	components := o.types[T]
+	return fromComponentArray(&components, T)
 }
 
 // GetComponentsInChildren returns an iterator of all components matching the given type in all children.
@@ -160,24 +402,75 @@ func (o *Object) GetComponentsInChildren(T reflect.Type) iter.Iter {
 	var val interface{} = nil
 	var err error = nil
 	for val, err = objIter.Next(); err == nil; val, err = objIter.Next() {
-		componentList := &val.(*Object).components
-		if len(*componentList) > 0 {
-			cIter.Add(componentList)
+		components := val.(*Object).types[T]
+		if len(components) > 0 {
+			val.(*Object).checkAccess(T)
+			cIter.Add(&components)
 		}
 	}
 	return cIter
 }
 
-// Update all components in this object
+// Update all components in this object, in order, on the calling goroutine.
+// The step is scaled by the effective time scale of the object; if that is zero, nothing is updated.
 func (o *Object) Update(step float32, runtime ...*Runtime) {
 	activeRuntime := o.runtime
 	if len(runtime) > 0 {
 		activeRuntime = runtime[0]
 	}
+	o.update(step, o.EffectiveTimeScale(), activeRuntime, nil)
+}
+
+// update runs all components in this object with an already known effective time scale, and
+// records what it did in stats, if any
+func (o *Object) update(step float32, scale float32, runtime *Runtime, stats *updateStats) {
+	if scale == 0 {
+		return
+	}
+	if stats != nil {
+		stats.object()
+	}
+	// The object logger is only needed by timers, so it is left to tickTimers; each component gets
+	// its own logger
+	context := &Context{Object: o, DeltaTime: step * scale, UnscaledDeltaTime: step, Runtime: runtime}
+	o.tickTimers(context)
+	o.updateComponents(context, runtime, stats, o.components)
+}
+
+// resume updates the components of the object after the given one, without ticking its timers; it
+// carries on an update that was abandoned by the watchdog. Nothing is updated if the component has
+// been removed since.
+func (o *Object) resume(step float32, scale float32, runtime *Runtime, stats *updateStats, after *componentInfo) {
 	clone := o.components
-	context := o.NewContext(step, activeRuntime)
 	for i := 0; i < len(clone); i++ {
-		clone[i].updateComponent(step, activeRuntime, context)
+		if clone[i] == after {
+			context := &Context{Object: o, DeltaTime: step * scale, UnscaledDeltaTime: step, Runtime: runtime}
+			o.updateComponents(context, runtime, stats, clone[i+1:])
+			return
+		}
+	}
+}
+
+// updateComponents starts or updates a set of the components of the object, in order
+func (o *Object) updateComponents(context *Context, runtime *Runtime, stats *updateStats, components []*componentInfo) {
+	var tracker *accessTracker
+	if runtime != nil {
+		tracker = runtime.accessTracker
+	}
+	for i := 0; i < len(components); i++ {
+		if !components[i].Disabled {
+			if tracker != nil {
+				tracker.enter(o, components[i])
+			}
+			if stats == nil {
+				components[i].updateComponent(context.forComponent(components[i]))
+			} else {
+				stats.update(o, components[i], context.forComponent(components[i]))
+			}
+		}
+	}
+	if tracker != nil {
+		tracker.enter(o, nil)
 	}
 }
 
@@ -187,11 +480,16 @@ func (o *Object) NewContext(delta float32, runtime ...*Runtime) *Context {
 	if len(runtime) > 0 {
 		activeRuntime = runtime[0]
 	}
+	var logger *slog.Logger
+	if activeRuntime != nil {
+		logger = annotatedLogger(&o.logger, activeRuntime.logger, o, nil)
+	}
 	return &Context{
-		Object:    o,
-		DeltaTime: delta,
-		Logger:    activeRuntime.logger,
-		Runtime:   activeRuntime,
+		Object:            o,
+		DeltaTime:         delta,
+		UnscaledDeltaTime: delta,
+		Logger:            logger,
+		Runtime:           activeRuntime,
 	}
 }
 
@@ -207,9 +505,20 @@ func (o *Object) Name() string {
 	return o.name
 }
 
-// Rename the object
-func (o *Object) Rename(name string) {
-	o.WithLock(func() error {
+// Rename the object.
+// If the object has a sibling with the new name, the name policy of the runtime applies.
+func (o *Object) Rename(name string) error {
+	if parent := o.parent; parent != nil {
+		switch parent.namePolicy() {
+		case RejectDuplicateNames:
+			if hasSiblingNamed(parent, name, o) {
+				return duplicateNameError(parent, name)
+			}
+		case SuffixDuplicateNames:
+			name = uniqueName(parent, name, o)
+		}
+	}
+	return o.WithLock(func() error {
 		o.name = name
 		return nil
 	})
@@ -220,6 +529,49 @@ func (o *Object) ID() string {
 	return o.id
 }
 
+// AddTag adds a tag to this object; adding a tag it already has does nothing.
+func (o *Object) AddTag(tag string) {
+	o.WithLock(func() error {
+		for i := 0; i < len(o.tags); i++ {
+			if o.tags[i] == tag {
+				return nil
+			}
+		}
+		o.tags = append(append(make([]string, 0, len(o.tags)+1), o.tags...), tag)
+		return nil
+	})
+}
+
+// RemoveTag removes a tag from this object; removing a tag it does not have does nothing.
+func (o *Object) RemoveTag(tag string) {
+	o.WithLock(func() error {
+		tags := make([]string, 0, len(o.tags))
+		for i := 0; i < len(o.tags); i++ {
+			if o.tags[i] != tag {
+				tags = append(tags, o.tags[i])
+			}
+		}
+		o.tags = tags
+		return nil
+	})
+}
+
+// HasTag checks if this object has the given tag.
+func (o *Object) HasTag(tag string) bool {
+	tags := o.tags
+	for i := 0; i < len(tags); i++ {
+		if tags[i] == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns a copy of the tags on this object.
+func (o *Object) Tags() []string {
+	return append([]string{}, o.tags...)
+}
+
 // Find returns the first matching component on the object tree given by the name sequence or nil
 // component should be a pointer to store the output component into.
 // eg. If *FakeComponent implements Component, pass **FakeComponent to Find.
@@ -259,14 +611,6 @@ func (o *Object) Runtime() *Runtime {
 	return nil
 }
 
-func (o *Object) Logger() *log.Logger {
-	runtime := o.runtime
-	if runtime != nil {
-		return runtime.logger
-	}
-	return nil
-}
-
 // FindObject returns the first matching child object on the object tree given by the name sequence or nil
 func (o *Object) FindObject(query ...string) (*Object, error) {
 	if len(query) == 0 {
@@ -294,23 +638,34 @@ func (o *Object) FindObject(query ...string) (*Object, error) {
 	return rtn, nil
 }
 
+// GetObject returns the first child with the given name.
+// If no child has exactly that name, an indexed name like "Enemy[2]" returns the child at that
+// index among the children named "Enemy", counting from zero.
 func (o *Object) GetObject(name string) (*Object, error) {
-	for i := 0; i < len(o.children); i++ {
-		if o.children[i].name == name {
-			return o.children[i], nil
+	children := o.children
+	for i := 0; i < len(children); i++ {
+		if children[i].name == name {
+			return children[i], nil
+		}
+	}
+	if base, index, ok := splitIndexedName(name); ok {
+		for i := 0; i < len(children); i++ {
+			if children[i].name == base {
+				if index == 0 {
+					return children[i], nil
+				}
+				index -= 1
+			}
 		}
 	}
 	return nil, errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for object '%s' on parent '%s'", name, o.name))
 }
 
 // HasObject is a single return value test for named object existence.
+// Indexed names are supported, as in GetObject.
 func (o *Object) HasObject(name string) bool {
-	for i := 0; i < len(o.children); i++ {
-		if o.children[i].name == name {
-			return true
-		}
-	}
-	return false
+	_, err := o.GetObject(name)
+	return err == nil
 }
 
 // Debug prints out a summary of the object and its components
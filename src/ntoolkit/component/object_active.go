@@ -0,0 +1,109 @@
+package component
+
+import (
+	"fmt"
+
+	"ntoolkit/errors"
+)
+
+// Active returns the active flag of this object itself; see ActiveInHierarchy.
+func (o *Object) Active() bool {
+	return !o.inactive
+}
+
+// ActiveInHierarchy checks if this object and all of its parents are active.
+// Only objects that are active in the hierarchy are updated.
+func (o *Object) ActiveInHierarchy() bool {
+	for cursor := o; cursor != nil; cursor = cursor.parent {
+		if cursor.inactive {
+			return false
+		}
+	}
+	return true
+}
+
+// SetActive activates or deactivates this object. An inactive object and all of its children keep
+// their place in the tree and their state, but are not updated. OnEnable or OnDisable is invoked on
+// the enabled components of every object that this changes ActiveInHierarchy for.
+func (o *Object) SetActive(active bool) {
+	wasActive := o.ActiveInHierarchy()
+	o.WithLock(func() error {
+		o.inactive = !active
+		return nil
+	})
+	o.notifyActiveChange(wasActive)
+}
+
+// IsEnabled checks if a component on this object is enabled.
+func (o *Object) IsEnabled(component Component) bool {
+	info := o.componentInfo(component)
+	return info != nil && !info.Disabled
+}
+
+// SetEnabled enables or disables a component on this object. A disabled component stays attached
+// but is not started or updated. If the object is active in the hierarchy, OnEnable or OnDisable is
+// invoked when the state changes.
+func (o *Object) SetEnabled(component Component, enabled bool) error {
+	info := o.componentInfo(component)
+	if info == nil {
+		return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for component %s on object '%s'", typeName(component.Type()), o.name))
+	}
+	if info.Disabled == !enabled {
+		return nil
+	}
+	o.WithLock(func() error {
+		info.Disabled = !enabled
+		return nil
+	})
+	if o.ActiveInHierarchy() {
+		info.notifyActive(o.NewContext(0, o.Runtime()), enabled)
+	}
+	return nil
+}
+
+// componentInfo returns the attached info for a component, or nil
+func (o *Object) componentInfo(component Component) *componentInfo {
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if components[i].Component == component {
+			return components[i]
+		}
+	}
+	return nil
+}
+
+// notifyActiveChange invokes OnEnable or OnDisable in the subtree if ActiveInHierarchy has changed
+func (o *Object) notifyActiveChange(wasActive bool) {
+	if active := o.ActiveInHierarchy(); active != wasActive {
+		o.notifyActive(o.Runtime(), active)
+	}
+}
+
+// notifyActive invokes OnEnable or OnDisable on the enabled components of the object, and of the
+// children that are themselves active.
+func (o *Object) notifyActive(runtime *Runtime, active bool) {
+	components := o.components
+	if len(components) > 0 {
+		context := o.NewContext(0, runtime)
+		for i := 0; i < len(components); i++ {
+			if !components[i].Disabled {
+				components[i].notifyActive(context, active)
+			}
+		}
+	}
+	children := o.children
+	for i := 0; i < len(children); i++ {
+		if !children[i].inactive {
+			children[i].notifyActive(runtime, active)
+		}
+	}
+}
+
+// notifyActive invokes OnEnable or OnDisable on a component
+func (info *componentInfo) notifyActive(context *Context, active bool) {
+	if active && info.OnEnable != nil {
+		info.OnEnable.OnEnable(context.forComponent(info))
+	} else if !active && info.OnDisable != nil {
+		info.OnDisable.OnDisable(context.forComponent(info))
+	}
+}
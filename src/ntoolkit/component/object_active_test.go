@@ -0,0 +1,135 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestSetActive(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		parent := component.NewObject("Parent")
+		child := component.NewObject("Child")
+		fake := &FakeEnableComponent{}
+		child.AddComponent(fake)
+		parent.AddObject(child)
+		runtime.Root().AddObject(parent)
+
+		runtime.Update(1)
+		T.Assert(fake.Updates == 1)
+
+		parent.SetActive(false)
+		T.Assert(!parent.Active())
+		T.Assert(child.Active())
+		T.Assert(!child.ActiveInHierarchy())
+		T.Assert(fake.Disables == 1)
+
+		runtime.Update(1)
+		T.Assert(fake.Updates == 1)
+
+		// Changes inside an inactive subtree do not notify
+		child.SetActive(false)
+		child.SetActive(true)
+		T.Assert(fake.Disables == 1)
+		T.Assert(fake.Enables == 0)
+
+		parent.SetActive(true)
+		T.Assert(fake.Enables == 1)
+		runtime.Update(1)
+		T.Assert(fake.Updates == 2)
+
+		// Moving into an inactive parent
+		other := component.NewObject("Other")
+		other.SetActive(false)
+		runtime.Root().AddObject(other)
+		other.AddObject(child)
+		T.Assert(fake.Disables == 2)
+		T.Assert(child.Parent() == other)
+	})
+}
+
+func TestSetEnabled(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject("A")
+		fake := &FakeEnableComponent{}
+		start := &FakeStartComponent{}
+		obj.AddComponent(fake)
+		obj.AddComponent(start)
+		runtime.Root().AddObject(obj)
+
+		T.Assert(obj.IsEnabled(fake))
+		T.Assert(obj.SetEnabled(fake, false) == nil)
+		T.Assert(obj.SetEnabled(fake, false) == nil)
+		T.Assert(!obj.IsEnabled(fake))
+		T.Assert(fake.Disables == 1)
+
+		T.Assert(obj.SetEnabled(start, false) == nil)
+		runtime.Update(1)
+		T.Assert(fake.Updates == 0)
+		T.Assert(start.Starts == 0)
+
+		T.Assert(obj.SetEnabled(fake, true) == nil)
+		T.Assert(fake.Enables == 1)
+		runtime.Update(1)
+		T.Assert(fake.Updates == 1)
+
+		T.Assert(errors.Is(obj.SetEnabled(&FakeComponent{}, false), component.ErrNoMatch{}))
+		T.Assert(!obj.IsEnabled(&FakeComponent{}))
+	})
+}
+
+func TestActiveStateIsSerialized(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		factory.Register(&FakeEnableComponent{})
+
+		parent := component.NewObject("Parent")
+		child := component.NewObject("Child")
+		fake := &FakeEnableComponent{}
+		child.AddComponent(fake)
+		child.AddComponent(&FakeEnableComponent{})
+		parent.AddObject(child)
+		parent.SetActive(false)
+		child.SetEnabled(fake, false)
+
+		template, err := factory.Serialize(parent)
+		T.Assert(err == nil)
+		T.Assert(template.Inactive)
+		T.Assert(template.Objects[0].Components[0].Disabled)
+		T.Assert(!template.Objects[0].Components[1].Disabled)
+
+		raw, err := component.ObjectTemplateAsYaml(template)
+		T.Assert(err == nil)
+		template, err = component.ObjectTemplateFromYaml(string(raw))
+		T.Assert(err == nil)
+		raw, err = component.ObjectTemplateAsToml(template)
+		T.Assert(err == nil)
+		template, err = component.ObjectTemplateFromToml(string(raw))
+		T.Assert(err == nil)
+
+		clone, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+		T.Assert(!clone.Active())
+		cloneChild, err := clone.GetObject("Child")
+		T.Assert(err == nil)
+		fakes := component.GetComponents[*FakeEnableComponent](cloneChild)
+		T.Assert(!cloneChild.IsEnabled(fakes[0]))
+		T.Assert(cloneChild.IsEnabled(fakes[1]))
+		T.Assert(fakes[0].Disables == 0)
+
+		// Patches carry the same state
+		after, err := factory.Serialize(parent)
+		T.Assert(err == nil)
+		parent.SetActive(true)
+		child.SetEnabled(fake, true)
+		before, err := factory.Serialize(parent)
+		T.Assert(err == nil)
+		patched, err := component.ApplyPatch(before, component.DiffTemplates(before, after))
+		T.Assert(err == nil)
+		T.Assert(patched.Inactive)
+		T.Assert(patched.Objects[0].Components[0].Disabled)
+	})
+}
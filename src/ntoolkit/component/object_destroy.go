@@ -0,0 +1,72 @@
+package component
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"ntoolkit/errors"
+)
+
+// Destroy removes the object from its parent, cancels every timer and coroutine on the object and
+// its children, and invokes OnDestroy on their components, children first. The object should not be
+// used afterwards. If any OnDestroy fails, the others are still invoked and an ErrDestroyFailed with
+// all of the failures is returned.
+func (o *Object) Destroy() error {
+	runtime := o.Runtime()
+	if parent := o.Parent(); parent != nil {
+		if err := parent.RemoveObject(o); err != nil {
+			return err
+		}
+	}
+	failures := o.destroy(runtime, nil)
+	return destroyFailed(failures, fmt.Sprintf("Failed to destroy object '%s'", o.name))
+}
+
+// destroy cancels the timers and invokes the destroy hooks of an object and its children, and
+// appends any failures
+func (o *Object) destroy(runtime *Runtime, failures []error) []error {
+	children := o.children
+	for i := 0; i < len(children); i++ {
+		failures = children[i].destroy(runtime, failures)
+	}
+	o.cancelTimers(func(task scheduled) bool { return true })
+	components := o.components
+	if len(components) == 0 {
+		return failures
+	}
+	context := o.NewContext(0, runtime)
+	for i := 0; i < len(components); i++ {
+		if err := components[i].destroy(context.forComponent(components[i])); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return failures
+}
+
+// destroy invokes OnDestroy on a component, recovering from any panic
+func (info *componentInfo) destroy(context *Context) (err error) {
+	if info.OnDestroy == nil {
+		return nil
+	}
+	defer (func() {
+		if r := recover(); r != nil {
+			inner, ok := r.(error)
+			if !ok {
+				inner = fmt.Errorf("%v", r)
+			}
+			err = inner
+		}
+		if err != nil {
+			err = errors.Fail(ErrDestroyFailed{}, err, fmt.Sprintf("Failed to destroy component %s on object '%s'", typeName(info.Type), context.Object.name))
+		}
+	})()
+	return info.OnDestroy.OnDestroy(context)
+}
+
+// destroyFailed aggregates destroy failures into a single ErrDestroyFailed, or nil if there are none
+func destroyFailed(failures []error, message string) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return errors.Fail(ErrDestroyFailed{}, stderrors.Join(failures...), fmt.Sprintf("%s; %d destroy hooks failed", message, len(failures)))
+}
@@ -15,6 +15,7 @@ type ComponentProvider interface {
 // ObjectFactory is the overseer that can be used to convert between objects and object templates
 type ObjectFactory struct {
 	handlers map[string]ComponentProvider
+	services *Services // The services injected into deserialized components, if any
 }
 
 // NewObjectFactory returns a new object factory
@@ -29,7 +30,10 @@ func (factory *ObjectFactory) Register(provider ComponentProvider) {
 
 // Serialize converts an object into an ObjectTemplate
 func (factory *ObjectFactory) Serialize(object *Object) (*ObjectTemplate, error) {
-	obj := &ObjectTemplate{Name: object.name}
+	obj := &ObjectTemplate{Name: object.name, ID: object.id, Inactive: object.inactive}
+	if len(object.tags) > 0 {
+		obj.Tags = object.Tags()
+	}
 
 	// Assign each component
 	for i := 0; i < len(object.components); i++ {
@@ -52,31 +56,66 @@ func (factory *ObjectFactory) Serialize(object *Object) (*ObjectTemplate, error)
 	return obj, nil
 }
 
-// Deserialize converts an ObjectTemplate into an object
+// Deserialize converts an ObjectTemplate into an object.
+// Templates are prefabs, so new objects are always given new ids.
+// Component requirements are checked once all the components of an object are loaded; missing
+// dependencies are created if they are registered with the factory. If the factory has services,
+// they are injected into the components, and a missing service is an ErrMissingService.
 func (factory *ObjectFactory) Deserialize(template *ObjectTemplate) (*Object, error) {
+	return factory.deserialize(template, false)
+}
+
+// deserialize converts an ObjectTemplate into an object, keeping the ids in the template if keepIDs
+// is set, as a snapshot does
+func (factory *ObjectFactory) deserialize(template *ObjectTemplate, keepIDs bool) (*Object, error) {
 	obj := NewObject(template.Name)
+	if keepIDs && template.ID != "" {
+		obj.id = template.ID
+	}
+	if len(template.Tags) > 0 {
+		obj.tags = append([]string{}, template.Tags...)
+	}
 
 	// Add components
 	for i := 0; i < len(template.Components); i++ {
-		c, err := factory.deserializeComponent(&template.Components[i])
+		c, err := factory.newComponent(&template.Components[i], factory.services)
 		if err != nil {
 			return nil, err
 		}
-		obj.AddComponent(c)
+		obj.attachComponent(c).Disabled = template.Components[i].Disabled
+	}
+	if err := obj.checkDependencies(factory); err != nil {
+		return nil, err
 	}
 
 	// Add children
 	for i := 0; i < len(template.Objects); i++ {
-		child, err := factory.Deserialize(&template.Objects[i])
+		child, err := factory.deserialize(&template.Objects[i], keepIDs)
 		if err != nil {
 			return nil, err
 		}
-		obj.AddObject(child)
+		if err := obj.AddObject(child); err != nil {
+			return nil, err
+		}
 	}
 
+	// Deactivate last, so adding the children does not notify them
+	obj.inactive = template.Inactive
 	return obj, nil
 }
 
+// newComponent turns a component template into a component, and injects the services into it
+func (factory *ObjectFactory) newComponent(template *ComponentTemplate, services *Services) (Component, error) {
+	component, err := factory.deserializeComponent(template)
+	if err != nil {
+		return nil, err
+	}
+	if err := injectServices(component, services); err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
 // deserializeComponent turns a component template into a component
 func (factory *ObjectFactory) deserializeComponent(template *ComponentTemplate) (Component, error) {
 	for k, v := range factory.handlers {
@@ -97,7 +136,8 @@ func (factory *ObjectFactory) deserializeComponent(template *ComponentTemplate)
 // serializeComponent converts a component into a template
 func (factory *ObjectFactory) serializeComponent(component *componentInfo) (*ComponentTemplate, error) {
 	template := &ComponentTemplate{
-		Type: typeName(component.Type)}
+		Type:     typeName(component.Type),
+		Disabled: component.Disabled}
 	if component.Persist != nil {
 		data, err := component.Persist.Serialize()
 		if err != nil {
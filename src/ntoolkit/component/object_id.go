@@ -9,6 +9,7 @@ import (
 
 var once sync.Once
 var randomSeed *rand.Rand // Random number generator
+var randomLock sync.Mutex // Guards randomSeed; objects are created on any goroutine, eg. by replication clients
 
 func makeTimestamp() int64 {
 	return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
@@ -16,7 +17,10 @@ func makeTimestamp() int64 {
 
 func makeObjectId() string {
 	once.Do(initRand)
-	return fmt.Sprintf("%d-%d", makeTimestamp(), randomSeed.Int63())
+	randomLock.Lock()
+	id := randomSeed.Int63()
+	randomLock.Unlock()
+	return fmt.Sprintf("%d-%d", makeTimestamp(), id)
 }
 
 func initRand() {
@@ -0,0 +1,137 @@
+package component
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ntoolkit/errors"
+)
+
+// NamePolicy controls how a runtime treats sibling objects with the same name.
+type NamePolicy int
+
+const (
+	AllowDuplicateNames  NamePolicy = iota // Siblings may share a name; use an indexed name like "Enemy[2]" to pick one
+	RejectDuplicateNames                   // AddObject, InsertObject and Rename fail with ErrBadObject on a duplicate name
+	SuffixDuplicateNames                   // Duplicate names are made unique with a suffix, eg. "Enemy (1)"
+)
+
+// namePolicy returns the name policy for the runtime the object is in
+func (o *Object) namePolicy() NamePolicy {
+	runtime := o.Runtime()
+	if runtime == nil {
+		return AllowDuplicateNames
+	}
+	return runtime.namePolicy
+}
+
+// applyNamePolicy applies the name policy of the parent's runtime to an object about to be added to it.
+// If the object is new to the runtime the names inside its subtree are checked as well. Duplicates are
+// either rejected, leaving every name unchanged, or renamed.
+func applyNamePolicy(parent *Object, object *Object) error {
+	policy := parent.namePolicy()
+	if policy == AllowDuplicateNames {
+		return nil
+	}
+	entering := object.Runtime() != parent.Runtime()
+
+	if policy == RejectDuplicateNames {
+		if hasSiblingNamed(parent, object.name, object) {
+			return duplicateNameError(parent, object.name)
+		}
+		if entering {
+			if owner, name := findDuplicateName(object); owner != nil {
+				return duplicateNameError(owner, name)
+			}
+		}
+		return nil
+	}
+
+	name := uniqueName(parent, object.name, object)
+	object.WithLock(func() error {
+		object.name = name
+		return nil
+	})
+	if entering {
+		suffixDuplicateNames(object)
+	}
+	return nil
+}
+
+// duplicateNameError returns the ErrBadObject for a duplicate child name
+func duplicateNameError(parent *Object, name string) error {
+	return errors.Fail(ErrBadObject{}, nil, fmt.Sprintf("Object '%s' already has a child named '%s'", parent.name, name))
+}
+
+// hasSiblingNamed checks if any child of parent other than except has the given name
+func hasSiblingNamed(parent *Object, name string, except *Object) bool {
+	children := parent.children
+	for i := 0; i < len(children); i++ {
+		if children[i] != except && children[i].name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueName returns name, or name with the lowest free suffix if another child of parent has it
+func uniqueName(parent *Object, name string, except *Object) string {
+	rtn := name
+	for i := 1; hasSiblingNamed(parent, rtn, except); i++ {
+		rtn = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return rtn
+}
+
+// findDuplicateName returns the first object in the subtree with two children of the same name
+func findDuplicateName(object *Object) (*Object, string) {
+	seen := make(map[string]bool, len(object.children))
+	for i := 0; i < len(object.children); i++ {
+		name := object.children[i].name
+		if seen[name] {
+			return object, name
+		}
+		seen[name] = true
+	}
+	for i := 0; i < len(object.children); i++ {
+		if owner, name := findDuplicateName(object.children[i]); owner != nil {
+			return owner, name
+		}
+	}
+	return nil, ""
+}
+
+// suffixDuplicateNames renames every child in the subtree that has the same name as an earlier sibling
+func suffixDuplicateNames(object *Object) {
+	for i := 0; i < len(object.children); i++ {
+		child := object.children[i]
+		for j := 0; j < i; j++ {
+			if object.children[j].name == child.name {
+				name := uniqueName(object, child.name, child)
+				child.WithLock(func() error {
+					child.name = name
+					return nil
+				})
+				break
+			}
+		}
+		suffixDuplicateNames(child)
+	}
+}
+
+// splitIndexedName splits an indexed name like "Enemy[2]" into its name and index.
+func splitIndexedName(name string) (string, int, bool) {
+	if !strings.HasSuffix(name, "]") {
+		return name, 0, false
+	}
+	offset := strings.LastIndex(name, "[")
+	if offset < 0 {
+		return name, 0, false
+	}
+	index, err := strconv.Atoi(name[offset+1 : len(name)-1])
+	if err != nil || index < 0 || strings.HasPrefix(name[offset+1:], "+") {
+		return name, 0, false
+	}
+	return name[:offset], index, true
+}
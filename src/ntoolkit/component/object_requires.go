@@ -0,0 +1,102 @@
+package component
+
+import (
+	"fmt"
+	"reflect"
+
+	"ntoolkit/errors"
+)
+
+// planDependencies appends the components that must be added for a component's requirements to be
+// met, dependencies first. Types that are already planned count as present, so circular
+// requirements are only created once. Nothing is changed on the object.
+func (o *Object) planDependencies(component Component, factory *ObjectFactory, planned map[reflect.Type]bool, output *[]Component) error {
+	requires, ok := component.(Requires)
+	if !ok {
+		return nil
+	}
+	required := requires.Requires()
+	for i := 0; i < len(required); i++ {
+		T := required[i]
+		if o.hasComponentType(T) || satisfiesType(planned, T) {
+			continue
+		}
+		var provider ComponentProvider
+		if factory != nil {
+			provider = factory.provider(T)
+		}
+		if provider == nil {
+			return errors.Fail(ErrMissingDependency{}, nil, fmt.Sprintf("Component %s requires %s, which is not on object '%s' and has no provider", typeName(component.Type()), typeName(T), o.name))
+		}
+		dependency := provider.New()
+		planned[dependency.Type()] = true
+		if err := o.planDependencies(dependency, factory, planned, output); err != nil {
+			return err
+		}
+		*output = append(*output, dependency)
+	}
+	return nil
+}
+
+// checkDependencies makes sure every component on the object has its requirements met, adding the
+// missing ones from the factory, with the factory services injected.
+func (o *Object) checkDependencies(factory *ObjectFactory) error {
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		dependencies := make([]Component, 0)
+		if err := o.planDependencies(components[i].Component, factory, map[reflect.Type]bool{}, &dependencies); err != nil {
+			return err
+		}
+		for j := 0; j < len(dependencies); j++ {
+			if err := injectServices(dependencies[j], factory.services); err != nil {
+				return err
+			}
+			o.attachComponent(dependencies[j])
+		}
+	}
+	return nil
+}
+
+// hasComponentType checks if the object has a component of the given type, or that implements it
+// if it is an interface.
+func (o *Object) hasComponentType(T reflect.Type) bool {
+	if T.Kind() != reflect.Interface {
+		return len(o.types[T]) > 0
+	}
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if components[i].Type.Implements(T) {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfiesType checks if any of a set of types is, or implements, T
+func satisfiesType(types map[reflect.Type]bool, T reflect.Type) bool {
+	if types[T] {
+		return true
+	}
+	if T.Kind() != reflect.Interface {
+		return false
+	}
+	for candidate := range types {
+		if candidate.Implements(T) {
+			return true
+		}
+	}
+	return false
+}
+
+// provider returns the registered provider for a concrete component type, or nil.
+// Interface types have no provider, as there is no single type to create.
+func (factory *ObjectFactory) provider(T reflect.Type) ComponentProvider {
+	if T.Kind() == reflect.Interface {
+		return nil
+	}
+	provider := factory.handlers[typeName(T)]
+	if provider == nil || provider.Type() != T {
+		return nil
+	}
+	return provider
+}
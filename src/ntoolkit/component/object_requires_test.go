@@ -0,0 +1,77 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestAddComponentWithMissingDependency(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		obj := component.NewObject("A")
+		err := obj.AddComponent(&FakeRequiresComponent{})
+		T.Assert(errors.Is(err, component.ErrMissingDependency{}))
+		T.Assert(len(component.GetComponents[component.Component](obj)) == 0)
+
+		_, err = component.AddComponent[*FakeRequiresComponent](obj)
+		T.Assert(errors.Is(err, component.ErrMissingDependency{}))
+
+		// Interface requirements have no provider, but any implementation will do
+		T.Assert(errors.Is(obj.AddComponent(&FakeRequiresUpdateComponent{}), component.ErrMissingDependency{}))
+		obj.AddComponent(&FakeStartComponent{})
+		T.Assert(obj.AddComponent(&FakeRequiresUpdateComponent{}) == nil)
+
+		obj.AddComponent(&FakeComponent{})
+		T.Assert(obj.AddComponent(&FakeRequiresComponent{}) == nil)
+		T.Assert(len(component.GetComponents[*FakeComponent](obj)) == 1)
+	})
+}
+
+func TestAddComponentAddsDependencies(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+		runtime := component.NewRuntime(component.Config{Factory: factory})
+
+		obj := component.NewObject("A")
+		runtime.Root().AddObject(obj)
+		T.Assert(obj.AddComponent(&FakeRequiresComponent{}) == nil)
+
+		all := component.GetComponents[component.Component](obj)
+		T.Assert(len(all) == 2)
+		_, ok := all[0].(*FakeComponent)
+		T.Assert(ok)
+		_, ok = all[1].(*FakeRequiresComponent)
+		T.Assert(ok)
+
+		T.Assert(obj.AddComponent(&FakeRequiresComponent{}) == nil)
+		T.Assert(len(component.GetComponents[*FakeComponent](obj)) == 1)
+	})
+}
+
+func TestDeserializeChecksDependencies(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		factory.Register(&FakeRequiresComponent{})
+
+		template := &component.ObjectTemplate{
+			Name:       "A",
+			Components: []component.ComponentTemplate{{Type: "*ntoolkit/component_test.FakeRequiresComponent"}}}
+		_, err := factory.Deserialize(template)
+		T.Assert(errors.Is(err, component.ErrMissingDependency{}))
+
+		// Dependencies later in the template are not added twice
+		factory.Register(&FakeComponent{})
+		template.Components = append(template.Components, component.ComponentTemplate{Type: "*ntoolkit/component_test.FakeComponent", Data: "1,0"})
+		obj, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+		T.Assert(len(component.GetComponents[*FakeComponent](obj)) == 1)
+
+		// Missing dependencies are created by the factory
+		template.Components = template.Components[:1]
+		obj, err = factory.Deserialize(template)
+		T.Assert(err == nil)
+		T.Assert(len(component.GetComponents[*FakeComponent](obj)) == 1)
+	})
+}
@@ -0,0 +1,279 @@
+package component
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"ntoolkit/errors"
+)
+
+// ObjectEncoder writes object trees as json directly to a writer.
+// Unlike ObjectFactory.Serialize, no intermediate ObjectTemplate is built; only one
+// component is held in memory at a time. The output is the same json that
+// ObjectTemplateAsJson produces for the equivalent template.
+type ObjectEncoder struct {
+	factory *ObjectFactory
+	writer  *bufio.Writer
+}
+
+// ObjectDecoder reads object trees from a json stream, building objects as it reads.
+// Only one component template is held in memory at a time. The input may be any json
+// accepted by ObjectTemplateFromJson.
+type ObjectDecoder struct {
+	factory *ObjectFactory
+	decoder *json.Decoder
+}
+
+// NewEncoder returns an encoder that writes to the given writer
+func (factory *ObjectFactory) NewEncoder(writer io.Writer) *ObjectEncoder {
+	return &ObjectEncoder{factory: factory, writer: bufio.NewWriter(writer)}
+}
+
+// NewDecoder returns a decoder that reads from the given reader
+func (factory *ObjectFactory) NewDecoder(reader io.Reader) *ObjectDecoder {
+	return &ObjectDecoder{factory: factory, decoder: json.NewDecoder(reader)}
+}
+
+// Encode writes the object tree followed by a newline.
+// The context is checked before each object and component; if it is done, ctx.Err() is returned
+// and the output is left incomplete.
+func (encoder *ObjectEncoder) Encode(ctx context.Context, object *Object) error {
+	if object == nil {
+		return errors.Fail(ErrNullValue{}, nil, "Invalid root object")
+	}
+	if err := encoder.encodeObject(ctx, object); err != nil {
+		return err
+	}
+	if err := encoder.writer.WriteByte('\n'); err != nil {
+		return err
+	}
+	return encoder.writer.Flush()
+}
+
+// encodeObject writes a single object and its children
+func (encoder *ObjectEncoder) encodeObject(ctx context.Context, object *Object) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name, err := json.Marshal(object.name)
+	if err != nil {
+		return errors.Fail(ErrBadValue{}, err, "Failed to encode object name")
+	}
+	id, err := json.Marshal(object.id)
+	if err != nil {
+		return errors.Fail(ErrBadValue{}, err, "Failed to encode object id")
+	}
+	encoder.writer.WriteString(`{"Name":`)
+	encoder.writer.Write(name)
+	encoder.writer.WriteString(`,"ID":`)
+	encoder.writer.Write(id)
+	if len(object.tags) > 0 {
+		tags, err := json.Marshal(object.tags)
+		if err != nil {
+			return errors.Fail(ErrBadValue{}, err, "Failed to encode object tags")
+		}
+		encoder.writer.WriteString(`,"Tags":`)
+		encoder.writer.Write(tags)
+	}
+	if object.inactive {
+		encoder.writer.WriteString(`,"Inactive":true`)
+	}
+
+	encoder.writer.WriteString(`,"Components":`)
+	if len(object.components) == 0 {
+		encoder.writer.WriteString("null")
+	} else {
+		encoder.writer.WriteByte('[')
+		for i := 0; i < len(object.components); i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			template, err := encoder.factory.serializeComponent(object.components[i])
+			if err != nil {
+				return err
+			}
+			raw, err := json.Marshal(template)
+			if err != nil {
+				return errors.Fail(ErrBadValue{}, err, fmt.Sprintf("Failed to encode component %s", template.Type))
+			}
+			if i > 0 {
+				encoder.writer.WriteByte(',')
+			}
+			encoder.writer.Write(raw)
+		}
+		encoder.writer.WriteByte(']')
+	}
+
+	encoder.writer.WriteString(`,"Objects":`)
+	if len(object.children) == 0 {
+		encoder.writer.WriteString("null")
+	} else {
+		encoder.writer.WriteByte('[')
+		for i := 0; i < len(object.children); i++ {
+			if i > 0 {
+				encoder.writer.WriteByte(',')
+			}
+			if err := encoder.encodeObject(ctx, object.children[i]); err != nil {
+				return err
+			}
+		}
+		encoder.writer.WriteByte(']')
+	}
+
+	_, err = encoder.writer.WriteString("}")
+	return err
+}
+
+// Decode reads the next object tree from the stream, or returns io.EOF if there are no more.
+// The context is checked before each object and component; if it is done, ctx.Err() is returned.
+func (decoder *ObjectDecoder) Decode(ctx context.Context) (*Object, error) {
+	if !decoder.decoder.More() {
+		return nil, io.EOF
+	}
+	return decoder.decodeObject(ctx)
+}
+
+// decodeObject reads a single object and its children
+func (decoder *ObjectDecoder) decodeObject(ctx context.Context) (*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := decoder.expectDelim('{'); err != nil {
+		return nil, err
+	}
+
+	obj := NewObject()
+	inactive := false
+	for decoder.decoder.More() {
+		key, err := decoder.token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := key.(string)
+		switch {
+		case strings.EqualFold(name, "Name"):
+			var value *string
+			if err := decoder.decoder.Decode(&value); err != nil {
+				return nil, decoder.fail(err, "Invalid object name")
+			}
+			if value != nil {
+				obj.name = *value
+			}
+		case strings.EqualFold(name, "Inactive"):
+			if err := decoder.decoder.Decode(&inactive); err != nil {
+				return nil, decoder.fail(err, "Invalid object active state")
+			}
+		case strings.EqualFold(name, "Tags"):
+			var value []string
+			if err := decoder.decoder.Decode(&value); err != nil {
+				return nil, decoder.fail(err, "Invalid object tags")
+			}
+			if len(value) > 0 {
+				obj.tags = value
+			}
+		case strings.EqualFold(name, "Components"):
+			if err := decoder.decodeList(func() error {
+				return decoder.decodeComponent(ctx, obj)
+			}); err != nil {
+				return nil, err
+			}
+		case strings.EqualFold(name, "Objects"):
+			if err := decoder.decodeList(func() error {
+				child, err := decoder.decodeObject(ctx)
+				if err != nil {
+					return err
+				}
+				return obj.AddObject(child)
+			}); err != nil {
+				return nil, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := decoder.decoder.Decode(&skip); err != nil {
+				return nil, decoder.fail(err, "Invalid value")
+			}
+		}
+	}
+
+	if err := decoder.expectDelim('}'); err != nil {
+		return nil, err
+	}
+	if err := obj.checkDependencies(decoder.factory); err != nil {
+		return nil, err
+	}
+	// Deactivate last, so adding the children does not notify them
+	obj.inactive = inactive
+	return obj, nil
+}
+
+// decodeComponent reads a single component template and attaches the component to the object
+func (decoder *ObjectDecoder) decodeComponent(ctx context.Context, obj *Object) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var template ComponentTemplate
+	if err := decoder.decoder.Decode(&template); err != nil {
+		return decoder.fail(err, "Invalid component")
+	}
+	component, err := decoder.factory.newComponent(&template, decoder.factory.services)
+	if err != nil {
+		return err
+	}
+	obj.attachComponent(component).Disabled = template.Disabled
+	return nil
+}
+
+// decodeList reads a json list, or null, invoking item for each element.
+// item must consume exactly one element from the stream.
+func (decoder *ObjectDecoder) decodeList(item func() error) error {
+	token, err := decoder.token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return decoder.fail(nil, "Expected a list")
+	}
+	for decoder.decoder.More() {
+		if err := item(); err != nil {
+			return err
+		}
+	}
+	return decoder.expectDelim(']')
+}
+
+// expectDelim reads the next token and checks that it is the given delimiter
+func (decoder *ObjectDecoder) expectDelim(expected json.Delim) error {
+	token, err := decoder.token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return decoder.fail(nil, fmt.Sprintf("Expected '%s'", expected))
+	}
+	return nil
+}
+
+// token reads the next json token
+func (decoder *ObjectDecoder) token() (json.Token, error) {
+	token, err := decoder.decoder.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, decoder.fail(err, "Invalid json")
+	}
+	return token, nil
+}
+
+// fail returns an ErrBadValue for the current stream offset
+func (decoder *ObjectDecoder) fail(err error, message string) error {
+	return errors.Fail(ErrBadValue{}, err, fmt.Sprintf("%s at offset %d", message, decoder.decoder.InputOffset()))
+}
@@ -0,0 +1,110 @@
+package component_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	c "ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+func TestEncodeMatchesTemplateJson(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+		factory.Register(&FakeConfiguredComponent{})
+
+		template, err := c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+		instance, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+
+		serialized, err := factory.Serialize(instance)
+		T.Assert(err == nil)
+		expected, err := c.ObjectTemplateAsJson(serialized)
+		T.Assert(err == nil)
+
+		var output bytes.Buffer
+		err = factory.NewEncoder(&output).Encode(context.Background(), instance)
+		T.Assert(err == nil)
+		T.Assert(strings.TrimSpace(output.String()) == string(expected))
+	})
+}
+
+func TestDecodeStream(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+		factory.Register(&FakeConfiguredComponent{})
+
+		decoder := factory.NewDecoder(strings.NewReader(objectTemplateNested + objectTemplateSimple))
+
+		instance, err := decoder.Decode(context.Background())
+		T.Assert(err == nil)
+
+		var cmp *FakeConfiguredComponent
+		err = instance.Find(&cmp, "One", "Two")
+		T.Assert(err == nil)
+		T.Assert(len(cmp.Data.Items) == 3)
+		T.Assert(cmp.Data.Items[2].Id == "3")
+
+		instance, err = decoder.Decode(context.Background())
+		T.Assert(err == nil)
+		T.Assert(instance.Name() == "Sample Object")
+
+		_, err = decoder.Decode(context.Background())
+		T.Assert(err == io.EOF)
+	})
+}
+
+func TestStreamRoundTrip(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+
+		root := c.NewObject("Root")
+		for i := 0; i < 10; i++ {
+			child := c.NewObject("Child")
+			child.AddComponent(&FakeComponent{Id: "Child", Count: i})
+			root.AddObject(child)
+		}
+
+		reader, writer := io.Pipe()
+		go (func() {
+			writer.CloseWithError(factory.NewEncoder(writer).Encode(context.Background(), root))
+		})()
+
+		copy, err := factory.NewDecoder(reader).Decode(context.Background())
+		T.Assert(err == nil)
+		T.Assert(copy.Debug() == root.Debug())
+	})
+}
+
+func TestStreamCancellation(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var output bytes.Buffer
+		err := factory.NewEncoder(&output).Encode(ctx, c.NewObject())
+		T.Assert(err == context.Canceled)
+
+		_, err = factory.NewDecoder(strings.NewReader(objectTemplateSimple)).Decode(ctx)
+		T.Assert(err == context.Canceled)
+	})
+}
+
+func TestDecodeInvalidStream(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		_, err := factory.NewDecoder(strings.NewReader(`{"Name": "Broken", "Objects": [`)).Decode(context.Background())
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+	})
+}
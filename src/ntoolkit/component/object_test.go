@@ -4,6 +4,7 @@ import (
 	"ntoolkit/assert"
 	"ntoolkit/component"
 	"ntoolkit/errors"
+	"ntoolkit/iter"
 	"testing"
 )
 
@@ -163,3 +164,182 @@ func TestRemoveChildWithChildren(T *testing.T) {
 		T.Assert(err == nil)
 	})
 }
+
+func TestSiblingIndex(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := component.NewObject("Root")
+		a := component.NewObject("A")
+		b := component.NewObject("B")
+		c := component.NewObject("C")
+
+		T.Assert(a.SiblingIndex() == -1)
+		T.Assert(errors.Is(a.SetSiblingIndex(0), component.ErrBadObject{}))
+
+		root.AddObject(a)
+		root.AddObject(b)
+		T.Assert(root.InsertObject(c, 0) == nil)
+		T.Assert(root.ChildCount() == 3)
+		T.Assert(c.SiblingIndex() == 0)
+		T.Assert(a.SiblingIndex() == 1)
+		T.Assert(b.SiblingIndex() == 2)
+
+		T.Assert(c.SetSiblingIndex(2) == nil)
+		first, err := root.Child(0)
+		T.Assert(err == nil)
+		T.Assert(first == a)
+		last, _ := root.Child(2)
+		T.Assert(last == c)
+
+		T.Assert(errors.Is(c.SetSiblingIndex(3), component.ErrBadValue{}))
+		T.Assert(errors.Is(root.InsertObject(component.NewObject(), 5), component.ErrBadValue{}))
+		_, err = root.Child(3)
+		T.Assert(errors.Is(err, component.ErrNoMatch{}))
+
+		// Inserting an existing child moves it
+		T.Assert(root.InsertObject(c, 0) == nil)
+		T.Assert(root.ChildCount() == 3)
+		T.Assert(c.SiblingIndex() == 0)
+	})
+}
+
+func TestSortChildren(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := component.NewObject("Root")
+		root.AddObject(component.NewObject("C"))
+		root.AddObject(component.NewObject("A"))
+		root.AddObject(component.NewObject("B"))
+
+		root.SortChildren(func(a *component.Object, b *component.Object) bool {
+			return a.Name() < b.Name()
+		})
+
+		for i, name := range []string{"A", "B", "C"} {
+			child, err := root.Child(i)
+			T.Assert(err == nil)
+			T.Assert(child.Name() == name)
+		}
+	})
+}
+
+func TestChildOrderIsSerialized(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		root := component.NewObject("Root")
+		root.AddObject(component.NewObject("A"))
+		root.AddObject(component.NewObject("B"))
+		c := component.NewObject("C")
+		root.InsertObject(c, 0)
+
+		template, err := factory.Serialize(root)
+		T.Assert(err == nil)
+		copy, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+
+		for i, name := range []string{"C", "A", "B"} {
+			child, err := copy.Child(i)
+			T.Assert(err == nil)
+			T.Assert(child.Name() == name)
+		}
+	})
+}
+
+func TestIndexedNames(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		root := component.NewObject("Root")
+		enemies := []*component.Object{component.NewObject("Enemy"), component.NewObject("Enemy"), component.NewObject("Enemy")}
+		for _, enemy := range enemies {
+			T.Assert(root.AddObject(enemy) == nil)
+		}
+		weapon := component.NewObject("Weapon")
+		enemies[2].AddObject(weapon)
+
+		r, err := root.GetObject("Enemy")
+		T.Assert(err == nil)
+		T.Assert(r == enemies[0])
+
+		r, err = root.GetObject("Enemy[2]")
+		T.Assert(err == nil)
+		T.Assert(r == enemies[2])
+
+		r, err = root.FindObject("Enemy[2]", "Weapon")
+		T.Assert(err == nil)
+		T.Assert(r == weapon)
+
+		_, err = root.GetObject("Enemy[3]")
+		T.Assert(errors.Is(err, component.ErrNoMatch{}))
+		T.Assert(root.HasObject("Enemy[1]"))
+		T.Assert(!root.HasObject("Enemy[-1]"))
+
+		literal := component.NewObject("Enemy[1]")
+		root.AddObject(literal)
+		r, err = root.GetObject("Enemy[1]")
+		T.Assert(err == nil)
+		T.Assert(r == literal)
+
+		results, err := iter.Collect(root.Query("Enemy[2]/Weapon"))
+		T.Assert(err == nil)
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == weapon)
+	})
+}
+
+func TestRejectDuplicateNames(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{NamePolicy: component.RejectDuplicateNames})
+		T.Assert(runtime.NamePolicy() == component.RejectDuplicateNames)
+
+		a := component.NewObject("Enemy")
+		b := component.NewObject("Enemy")
+		T.Assert(runtime.Root().AddObject(a) == nil)
+		T.Assert(errors.Is(runtime.Root().AddObject(b), component.ErrBadObject{}))
+		T.Assert(errors.Is(runtime.Root().InsertObject(b, 0), component.ErrBadObject{}))
+		T.Assert(b.Parent() == nil)
+
+		b.Rename("Other")
+		T.Assert(runtime.Root().AddObject(b) == nil)
+		T.Assert(errors.Is(b.Rename("Enemy"), component.ErrBadObject{}))
+		T.Assert(b.Name() == "Other")
+		T.Assert(a.Rename("Enemy") == nil)
+
+		group := component.NewObject("Group")
+		group.AddObject(component.NewObject("Twin"))
+		group.AddObject(component.NewObject("Twin"))
+		T.Assert(errors.Is(runtime.Root().AddObject(group), component.ErrBadObject{}))
+		T.Assert(group.ChildCount() == 2)
+	})
+}
+
+func TestSuffixDuplicateNames(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{NamePolicy: component.SuffixDuplicateNames})
+
+		a := component.NewObject("Enemy")
+		b := component.NewObject("Enemy")
+		c := component.NewObject("Enemy")
+		T.Assert(runtime.Root().AddObject(a) == nil)
+		T.Assert(runtime.Root().AddObject(b) == nil)
+		T.Assert(runtime.Root().InsertObject(c, 0) == nil)
+		T.Assert(a.Name() == "Enemy")
+		T.Assert(b.Name() == "Enemy (1)")
+		T.Assert(c.Name() == "Enemy (2)")
+
+		T.Assert(c.Rename("Enemy") == nil)
+		T.Assert(c.Name() == "Enemy (2)")
+
+		group := component.NewObject("Group")
+		twin := component.NewObject("Twin")
+		group.AddObject(component.NewObject("Twin"))
+		group.AddObject(twin)
+		T.Assert(runtime.Root().AddObject(group) == nil)
+		T.Assert(twin.Name() == "Twin (1)")
+
+		// A rejected circular insert renames nothing
+		enemy := component.NewObject("Enemy")
+		T.Assert(a.AddObject(enemy) == nil)
+		T.Assert(enemy.AddObject(component.NewObject("Enemy")) == nil)
+		T.Assert(errors.Is(enemy.AddObject(a), component.ErrBadObject{}))
+		T.Assert(errors.Is(enemy.InsertObject(a, 0), component.ErrBadObject{}))
+		T.Assert(a.Name() == "Enemy")
+		T.Assert(a.Parent() == runtime.Root())
+	})
+}
@@ -0,0 +1,46 @@
+package component
+
+import (
+	"fmt"
+	"math"
+
+	"ntoolkit/errors"
+)
+
+// TimeScale returns the time scale of this object itself; see EffectiveTimeScale.
+func (o *Object) TimeScale() float32 {
+	return o.timeScale
+}
+
+// SetTimeScale sets how fast time passes for this object and its children, relative to its parent;
+// 1 is normal speed. Scales multiply down the hierarchy, so a child at 0.5 under a parent at 0.5 runs
+// at a quarter speed. A scale of 0 pauses the object and its children, so they are not updated at all.
+// It fails with an ErrBadValue for a negative or non-finite scale.
+func (o *Object) SetTimeScale(scale float32) error {
+	if !validTimeScale(scale) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid time scale %v", scale))
+	}
+	return o.WithLock(func() error {
+		o.timeScale = scale
+		return nil
+	})
+}
+
+// validTimeScale checks if a time scale is finite and not negative
+func validTimeScale(scale float32) bool {
+	return scale >= 0 && !math.IsInf(float64(scale), 0)
+}
+
+// EffectiveTimeScale returns the product of the time scales of this object and all of its parents.
+func (o *Object) EffectiveTimeScale() float32 {
+	scale := float32(1)
+	for cursor := o; cursor != nil; cursor = cursor.parent {
+		scale *= cursor.timeScale
+	}
+	return scale
+}
+
+// Paused checks if this object or one of its parents has a time scale of zero.
+func (o *Object) Paused() bool {
+	return o.EffectiveTimeScale() == 0
+}
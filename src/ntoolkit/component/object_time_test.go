@@ -0,0 +1,65 @@
+package component_test
+
+import (
+	"math"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestTimeScale(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		world := component.NewObject("World")
+		zone := component.NewObject("Zone")
+		obj := component.NewObject("Bullet")
+		fake := &FakeTimeComponent{}
+		obj.AddComponent(fake)
+		zone.AddObject(obj)
+		world.AddObject(zone)
+		runtime.Root().AddObject(world)
+
+		T.Assert(world.SetTimeScale(0.5) == nil)
+		T.Assert(zone.SetTimeScale(0.5) == nil)
+		T.Assert(obj.EffectiveTimeScale() == 0.25)
+		T.Assert(errors.Is(zone.SetTimeScale(-1), component.ErrBadValue{}))
+		T.Assert(errors.Is(zone.SetTimeScale(float32(math.NaN())), component.ErrBadValue{}))
+		T.Assert(errors.Is(zone.SetTimeScale(float32(math.Inf(1))), component.ErrBadValue{}))
+		T.Assert(zone.TimeScale() == 0.5)
+
+		runtime.Update(2)
+		T.Assert(fake.Updates == 1)
+		T.Assert(fake.Delta == 0.5)
+		T.Assert(fake.Unscaled == 2)
+
+		zone.SetTimeScale(1)
+		runtime.Update(4)
+		T.Assert(fake.Updates == 2)
+		T.Assert(fake.Delta == 2)
+		T.Assert(fake.Unscaled == 4)
+	})
+}
+
+func TestPause(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		menu := component.NewObject("Menu")
+		obj := component.NewObject("Button")
+		fake := &FakeTimeComponent{}
+		obj.AddComponent(fake)
+		menu.AddObject(obj)
+		runtime.Root().AddObject(menu)
+
+		menu.SetTimeScale(0)
+		T.Assert(obj.Paused())
+		runtime.Update(1)
+		T.Assert(fake.Updates == 0)
+
+		menu.SetTimeScale(1)
+		T.Assert(!obj.Paused())
+		runtime.Update(1)
+		T.Assert(fake.Updates == 1)
+		T.Assert(fake.Delta == 1)
+	})
+}
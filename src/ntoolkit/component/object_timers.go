@@ -0,0 +1,366 @@
+package component
+
+import (
+	"fmt"
+	"sync"
+
+	"ntoolkit/errors"
+)
+
+// scheduled is a timer or coroutine that is advanced by the updates of its object
+type scheduled interface {
+	// tick advances the task by the scaled delta time of the context, and returns false once it is finished
+	tick(context *Context) bool
+
+	// owner returns the component that scheduled the task, if any
+	owner() Component
+
+	// Cancel stops the task
+	Cancel()
+}
+
+// Timer is a handle for a callback scheduled with Context.After or Context.Every.
+type Timer struct {
+	component Component
+	action    func(context *Context)
+	remaining float32 // The time left until the action is next invoked
+	interval  float32 // The time between repeats, if repeating
+	repeat    bool
+	cancelled bool
+	lock      *sync.Mutex
+}
+
+// Cancel stops the timer; the action is not invoked again.
+func (timer *Timer) Cancel() {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+	timer.cancelled = true
+}
+
+// Cancelled checks if the timer was cancelled, either directly or because its component was removed
+// or its object removed or destroyed. A one shot timer that has fired is not cancelled.
+func (timer *Timer) Cancelled() bool {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+	return timer.cancelled
+}
+
+func (timer *Timer) owner() Component {
+	return timer.component
+}
+
+func (timer *Timer) tick(context *Context) bool {
+	timer.remaining -= context.DeltaTime
+	for !timer.Cancelled() && timer.remaining <= 0 {
+		timer.action(context)
+		if !timer.repeat {
+			return false
+		}
+		if timer.interval <= 0 {
+			timer.remaining = 0
+			break
+		}
+		timer.remaining += timer.interval
+	}
+	return !timer.Cancelled()
+}
+
+// Coroutine is a function running in its own goroutine that spans several frames.
+// It only ever runs while the frame loop is blocked on it, during the update of its object, so it
+// is safe to touch the object and its components as if it were an Update. Between frames it is
+// parked in one of the Wait calls.
+type Coroutine struct {
+	component Component
+	context   *Context      // The context of the frame the coroutine is running in
+	remaining float32       // The time left until the coroutine is resumed
+	condition func() bool   // The condition to resume on, if any
+	resume    chan bool     // Resumes the coroutine; false if it was cancelled
+	yield     chan struct{} // Returns control to the frame loop
+	parked    bool          // The coroutine is waiting on resume
+	running   bool          // The coroutine was resumed by the frame loop and must yield back; coroutine goroutine only
+	cancelled bool
+	finished  bool
+	err       error // The failure of the coroutine, if it panicked
+	lock      *sync.Mutex
+}
+
+// Context returns the context of the frame the coroutine is currently running in.
+func (co *Coroutine) Context() *Context {
+	return co.context
+}
+
+// Wait parks the coroutine until the given amount of scaled time has passed for its object.
+// It returns false if the coroutine was cancelled, in which case it should return; once cancelled
+// every Wait returns false immediately, and the coroutine no longer runs as part of a frame.
+func (co *Coroutine) Wait(seconds float32) bool {
+	co.lock.Lock()
+	co.remaining = seconds
+	co.condition = nil
+	co.lock.Unlock()
+	return co.park()
+}
+
+// Yield parks the coroutine until the next update of its object; see Wait.
+func (co *Coroutine) Yield() bool {
+	return co.Wait(0)
+}
+
+// WaitUntil parks the coroutine until the condition is true at the start of an update of its
+// object; see Wait.
+func (co *Coroutine) WaitUntil(condition func() bool) bool {
+	co.lock.Lock()
+	co.remaining = 0
+	co.condition = condition
+	co.lock.Unlock()
+	return co.park()
+}
+
+// Cancel stops the coroutine; its current or next Wait returns false.
+func (co *Coroutine) Cancel() {
+	co.lock.Lock()
+	if co.cancelled || co.finished {
+		co.lock.Unlock()
+		return
+	}
+	co.cancelled = true
+	parked := co.parked
+	co.parked = false
+	co.lock.Unlock()
+	if parked {
+		co.resume <- false
+	}
+}
+
+// Cancelled checks if the coroutine was cancelled, either directly or because its component was
+// removed or its object removed or destroyed.
+func (co *Coroutine) Cancelled() bool {
+	co.lock.Lock()
+	defer co.lock.Unlock()
+	return co.cancelled
+}
+
+// Finished checks if the coroutine function has returned, or panicked.
+func (co *Coroutine) Finished() bool {
+	co.lock.Lock()
+	defer co.lock.Unlock()
+	return co.finished
+}
+
+// Err returns an ErrTaskFailed if the coroutine function panicked, or nil.
+func (co *Coroutine) Err() error {
+	co.lock.Lock()
+	defer co.lock.Unlock()
+	return co.err
+}
+
+func (co *Coroutine) owner() Component {
+	return co.component
+}
+
+// start runs the coroutine function once it is first resumed. A panic in the function finishes the
+// coroutine and is logged with the logger of its component, rather than crashing the process.
+func (co *Coroutine) start(action func(co *Coroutine)) {
+	go (func() {
+		defer (func() {
+			if r := recover(); r != nil {
+				co.fail(r)
+			}
+			co.lock.Lock()
+			co.finished = true
+			co.lock.Unlock()
+			co.release()
+		})()
+		if co.await() {
+			action(co)
+		}
+	})()
+}
+
+// fail records and logs a panic in the coroutine function
+func (co *Coroutine) fail(r interface{}) {
+	inner, ok := r.(error)
+	if !ok {
+		inner = fmt.Errorf("%v", r)
+	}
+	err := errors.Fail(ErrTaskFailed{}, inner, "Coroutine panicked")
+	co.lock.Lock()
+	co.err = err
+	context := co.context
+	co.lock.Unlock()
+	if context != nil && context.Logger != nil {
+		context.Logger.Error("Coroutine panicked", "error", err)
+	}
+}
+
+// park returns control to the frame loop and waits to be resumed
+func (co *Coroutine) park() bool {
+	co.lock.Lock()
+	if co.cancelled {
+		co.lock.Unlock()
+		co.release()
+		return false
+	}
+	co.parked = true
+	co.lock.Unlock()
+	co.release()
+	return co.await()
+}
+
+// await blocks until the coroutine is resumed or cancelled
+func (co *Coroutine) await() bool {
+	co.running = <-co.resume
+	return co.running
+}
+
+// release hands control back to the frame loop, if it is waiting on the coroutine
+func (co *Coroutine) release() {
+	if co.running {
+		co.running = false
+		co.yield <- struct{}{}
+	}
+}
+
+func (co *Coroutine) tick(context *Context) bool {
+	co.lock.Lock()
+	if co.cancelled || co.finished {
+		co.lock.Unlock()
+		return false
+	}
+	co.remaining -= context.DeltaTime
+	ready := co.parked && co.remaining <= 0
+	condition := co.condition
+	co.lock.Unlock()
+	if ready && condition != nil {
+		ready = condition()
+	}
+	if ready {
+		// Cancel may have resumed the coroutine in the meantime
+		co.lock.Lock()
+		ready = co.parked
+		co.parked = false
+		co.context = context
+		co.lock.Unlock()
+	}
+	if ready {
+		co.resume <- true
+		<-co.yield
+	}
+	co.lock.Lock()
+	defer co.lock.Unlock()
+	return !co.cancelled && !co.finished
+}
+
+// After invokes the action once the given amount of scaled time has passed for the object.
+// The timer belongs to the component of the context, and is cancelled if the component is removed
+// or the object is removed or destroyed. It is driven by updates, so it does not run while the object is
+// inactive or paused, and fires in the first update that reaches the delay, before the components of
+// the object are updated.
+func (context *Context) After(delay float32, action func(context *Context)) *Timer {
+	timer := &Timer{
+		component: context.Component,
+		action:    action,
+		remaining: delay,
+		lock:      &sync.Mutex{}}
+	context.Object.schedule(timer)
+	return timer
+}
+
+// Every invokes the action each time the given interval of scaled time passes for the object; if an
+// update spans several intervals, the action is invoked once for each. An interval of 0 or less
+// invokes the action every update. See After.
+func (context *Context) Every(interval float32, action func(context *Context)) *Timer {
+	timer := &Timer{
+		component: context.Component,
+		action:    action,
+		remaining: interval,
+		interval:  interval,
+		repeat:    true,
+		lock:      &sync.Mutex{}}
+	context.Object.schedule(timer)
+	return timer
+}
+
+// Go starts a coroutine on the object, which first runs during the next update of the object.
+// The coroutine belongs to the component of the context, and is cancelled if the component is
+// removed or the object is removed or destroyed. See Coroutine.
+func (context *Context) Go(action func(co *Coroutine)) *Coroutine {
+	co := &Coroutine{
+		component: context.Component,
+		resume:    make(chan bool),
+		yield:     make(chan struct{}),
+		parked:    true,
+		lock:      &sync.Mutex{}}
+	co.start(action)
+	context.Object.schedule(co)
+	return co
+}
+
+// schedule adds a timer or coroutine to the object
+func (o *Object) schedule(task scheduled) {
+	o.WithLock(func() error {
+		o.timers = append(o.timers[:len(o.timers):len(o.timers)], task)
+		return nil
+	})
+}
+
+// tickTimers advances the timers and coroutines on the object, and drops the finished ones
+func (o *Object) tickTimers(context *Context) {
+	timers := o.timers
+	if len(timers) == 0 {
+		return
+	}
+	if context.Logger == nil && context.Runtime != nil {
+		context.Logger = annotatedLogger(&o.logger, context.Runtime.logger, o, nil)
+	}
+	finished := make(map[scheduled]bool)
+	for i := 0; i < len(timers); i++ {
+		timerContext := context
+		if component := timers[i].owner(); component != nil {
+			if info := o.componentInfo(component); info != nil {
+				timerContext = context.forComponent(info)
+			}
+		}
+		if !timers[i].tick(timerContext) {
+			finished[timers[i]] = true
+		}
+	}
+	if len(finished) > 0 {
+		o.unschedule(func(task scheduled) bool { return finished[task] })
+	}
+}
+
+// cancelTimersInTree cancels and drops every timer and coroutine on the object and its children
+func (o *Object) cancelTimersInTree() {
+	children := o.children
+	for i := 0; i < len(children); i++ {
+		children[i].cancelTimersInTree()
+	}
+	o.cancelTimers(func(task scheduled) bool { return true })
+}
+
+// cancelTimers cancels and drops the timers and coroutines that match
+func (o *Object) cancelTimers(match func(task scheduled) bool) {
+	cancelled := o.unschedule(match)
+	for i := 0; i < len(cancelled); i++ {
+		cancelled[i].Cancel()
+	}
+}
+
+// unschedule drops the timers and coroutines that match, and returns them
+func (o *Object) unschedule(match func(task scheduled) bool) []scheduled {
+	removed := make([]scheduled, 0)
+	o.WithLock(func() error {
+		// Copy rather than modify in place, in case the timers are being ticked
+		timers := make([]scheduled, 0, len(o.timers))
+		for i := 0; i < len(o.timers); i++ {
+			if match(o.timers[i]) {
+				removed = append(removed, o.timers[i])
+			} else {
+				timers = append(timers, o.timers[i])
+			}
+		}
+		o.timers = timers
+		return nil
+	})
+	return removed
+}
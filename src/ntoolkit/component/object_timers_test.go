@@ -0,0 +1,188 @@
+package component_test
+
+import (
+	"bytes"
+	"log/slog"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestAfterAndEvery(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject("Trap")
+		fake := &FakeTimerComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+
+		// The timers are scheduled in the first frame
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Fired == 0)
+		T.Assert(fake.Ticks == 1)
+
+		runtime.Update(1)
+		T.Assert(fake.Fired == 1)
+		T.Assert(fake.Ticks == 2)
+
+		// Multiple intervals in one frame
+		runtime.Update(2)
+		T.Assert(fake.Fired == 1)
+		T.Assert(fake.Ticks == 4)
+
+		// Paused objects do not advance their timers
+		obj.SetTimeScale(0)
+		runtime.Update(5)
+		T.Assert(fake.Ticks == 4)
+		obj.SetTimeScale(1)
+
+		obj.RemoveComponent(fake)
+		T.Assert(fake.Every.Cancelled())
+		runtime.Update(1)
+		T.Assert(fake.Ticks == 4)
+	})
+}
+
+func TestCoroutine(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+
+		runtime.Update(1)
+		T.Assert(fake.Steps == 0)
+
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+
+		runtime.Update(1)
+		T.Assert(fake.Steps == 2)
+
+		runtime.Update(1)
+		T.Assert(fake.Steps == 3)
+
+		for i := 0; i < 10; i++ {
+			runtime.Update(1)
+		}
+		<-fake.Done
+		T.Assert(fake.Steps == 10)
+		T.Assert(!fake.Cancelled)
+		T.Assert(fake.Routine.Finished())
+	})
+}
+
+func TestDestroyCancelsCoroutine(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		parent := component.NewObject("Room")
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{}
+		obj.AddComponent(fake)
+		parent.AddObject(obj)
+		runtime.Root().AddObject(parent)
+
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+
+		T.Assert(parent.Destroy() == nil)
+		T.Assert(!runtime.Root().HasObject("Room"))
+		<-fake.Done
+		T.Assert(fake.Cancelled)
+		T.Assert(fake.Steps == 1)
+		T.Assert(fake.Routine.Cancelled())
+	})
+}
+
+func TestRemoveObjectCancelsCoroutine(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		parent := component.NewObject("Room")
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{}
+		obj.AddComponent(fake)
+		parent.AddObject(obj)
+		runtime.Root().AddObject(parent)
+
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+
+		T.Assert(runtime.Root().RemoveObject(parent) == nil)
+		<-fake.Done
+		T.Assert(fake.Cancelled)
+		T.Assert(fake.Routine.Cancelled())
+	})
+}
+
+func TestExtractCancelsCoroutine(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+		runtime.Update(1)
+		runtime.Update(1)
+
+		_, err := runtime.Extract(obj)
+		T.Assert(err == nil)
+		<-fake.Done
+		T.Assert(fake.Cancelled)
+	})
+}
+
+func TestMoveKeepsCoroutine(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		room := component.NewObject("Room")
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+		runtime.Root().AddObject(room)
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+
+		T.Assert(room.AddObject(obj) == nil)
+		T.Assert(!fake.Routine.Cancelled())
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Steps == 2)
+	})
+}
+
+func TestCoroutinePanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		output := &bytes.Buffer{}
+		runtime := component.NewRuntime(component.Config{LogHandler: slog.NewJSONHandler(output, nil)})
+		obj := component.NewObject("Door")
+		fake := &FakeCoroutineComponent{Panic: true}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+
+		runtime.Update(1)
+		runtime.Update(1)
+		T.Assert(fake.Steps == 1)
+		T.Assert(fake.Routine.Finished())
+		T.Assert(errors.Is(fake.Routine.Err(), component.ErrTaskFailed{}))
+
+		records := logRecords(output)
+		T.Assert(len(records) == 1)
+		T.Assert(records[0]["level"] == "ERROR")
+		T.Assert(records[0]["msg"] == "Coroutine panicked")
+		T.Assert(records[0]["object_path"] == "/Door")
+		T.Assert(records[0]["component"] == "*ntoolkit/component_test.FakeCoroutineComponent")
+
+		// The runtime carries on
+		T.Assert(runtime.Update(1) == nil)
+	})
+}
@@ -0,0 +1,677 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"ntoolkit/errors"
+)
+
+// PatchOp is the kind of change made by a PatchOperation.
+type PatchOp string
+
+const (
+	PatchAddObject       PatchOp = "AddObject"       // Add Template, without children, as Object under Parent
+	PatchRemoveObject    PatchOp = "RemoveObject"    // Remove Object and all of its children
+	PatchMoveObject      PatchOp = "MoveObject"      // Move Object to the end of the children of Parent
+	PatchOrderObjects    PatchOp = "OrderObjects"    // Reorder the children of Object to match Children
+	PatchRenameObject    PatchOp = "RenameObject"    // Rename Object to Name
+	PatchSetTags         PatchOp = "SetTags"         // Replace the tags of Object with Tags
+	PatchSetActive       PatchOp = "SetActive"       // Activate Object if Value is true, or deactivate it
+	PatchAddComponent    PatchOp = "AddComponent"    // Append a component of Type with data Value to Object
+	PatchRemoveComponent PatchOp = "RemoveComponent" // Remove component Index of Type from Object
+	PatchOrderComponents PatchOp = "OrderComponents" // Reorder the components of Object so their types match Types
+	PatchSetData         PatchOp = "SetData"         // Replace the data of component Index of Type with Value
+	PatchSetField        PatchOp = "SetField"        // Set the data field at path Field of component Index of Type
+	PatchRemoveField     PatchOp = "RemoveField"     // Remove the data field at path Field of component Index of Type
+	PatchSetEnabled      PatchOp = "SetEnabled"      // Enable component Index of Type if Value is true, or disable it
+)
+
+// PatchOperation is a single change to an object template tree.
+// Objects are identified by their ID, and the root object is always "". Objects without an ID are
+// identified by their parent and position, eg. "parent#2". Components are identified by their Type
+// and their Index among the components of that type on the object; reordering components never
+// changes the order of components of the same type, so it never changes their Index.
+type PatchOperation struct {
+	Op       PatchOp
+	Object   string
+	Parent   string
+	Name     string
+	Template *ObjectTemplate
+	Children []string
+	Tags     []string
+	Types    []string
+	Type     string
+	Index    int
+	Field    []string
+	Value    interface{}
+}
+
+// Patch is a serializable set of changes between two object template trees.
+type Patch struct {
+	Operations []PatchOperation
+}
+
+// PatchFromJson loads a patch from a json block.
+func PatchFromJson(raw string) (*Patch, error) {
+	var data Patch
+	err := json.Unmarshal([]byte(raw), &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// PatchAsJson saves a patch as a json block.
+func PatchAsJson(patch *Patch) ([]byte, error) {
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// DiffTemplates returns the patch that turns a into b.
+// Component data is compared as json values; nested maps are diffed field by field.
+func DiffTemplates(a, b *ObjectTemplate) Patch {
+	before := newPatchTree(a)
+	after := newPatchTree(b)
+	simulation := newPatchTree(a)
+	patch := Patch{Operations: make([]PatchOperation, 0)}
+	emit := func(op PatchOperation) {
+		patch.Operations = append(patch.Operations, op)
+		simulation.apply(&op)
+	}
+
+	// Add new objects, parents first, then move existing objects into place and remove
+	// old ones; children that survive are moved out before their old parent is removed.
+	after.walk(func(node *patchNode) {
+		if node.parent != nil && before.nodes[node.key] == nil {
+			template := node.template
+			emit(PatchOperation{Op: PatchAddObject, Object: node.key, Parent: node.parent.key, Template: &template})
+		}
+	})
+	after.walk(func(node *patchNode) {
+		if node.parent != nil {
+			if old := before.nodes[node.key]; old != nil && old.parent.key != node.parent.key {
+				emit(PatchOperation{Op: PatchMoveObject, Object: node.key, Parent: node.parent.key})
+			}
+		}
+	})
+	before.walk(func(node *patchNode) {
+		if node.parent != nil && after.nodes[node.key] == nil && after.nodes[node.parent.key] != nil {
+			emit(PatchOperation{Op: PatchRemoveObject, Object: node.key})
+		}
+	})
+
+	// Fix the order of any children that are not where they should be
+	after.walk(func(node *patchNode) {
+		expected := node.childKeys()
+		if !reflect.DeepEqual(simulation.nodes[node.key].childKeys(), expected) {
+			emit(PatchOperation{Op: PatchOrderObjects, Object: node.key, Children: expected})
+		}
+	})
+
+	// Finally, update the names, tags, active states and components of existing objects
+	after.walk(func(node *patchNode) {
+		old := before.nodes[node.key]
+		if old == nil {
+			return
+		}
+		if old.template.Name != node.template.Name {
+			emit(PatchOperation{Op: PatchRenameObject, Object: node.key, Name: node.template.Name})
+		}
+		if !equalTags(old.template.Tags, node.template.Tags) {
+			emit(PatchOperation{Op: PatchSetTags, Object: node.key, Tags: node.template.Tags})
+		}
+		if old.template.Inactive != node.template.Inactive {
+			emit(PatchOperation{Op: PatchSetActive, Object: node.key, Value: !node.template.Inactive})
+		}
+		for _, op := range diffComponents(node.key, old.template.Components, node.template.Components) {
+			emit(op)
+		}
+	})
+
+	return patch
+}
+
+// ApplyPatch returns a copy of the template with the patch applied; the template itself is not modified.
+func ApplyPatch(template *ObjectTemplate, patch Patch) (*ObjectTemplate, error) {
+	tree := newPatchTree(template)
+	for i := 0; i < len(patch.Operations); i++ {
+		if err := tree.apply(&patch.Operations[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tree.template(), nil
+}
+
+// diffComponents returns the operations that turn one set of components into another.
+// New components are appended, and then all of the components are reordered if they are not in
+// the right order.
+func diffComponents(key string, before []ComponentTemplate, after []ComponentTemplate) []PatchOperation {
+	rtn := make([]PatchOperation, 0)
+	beforeCount := make(map[string]int)
+	afterCount := make(map[string]int)
+	for i := 0; i < len(after); i++ {
+		afterCount[after[i].Type] += 1
+	}
+
+	// Remove from the end, so the index of each earlier component of the same type is unchanged
+	removed := make([]PatchOperation, 0)
+	for i := 0; i < len(before); i++ {
+		index := beforeCount[before[i].Type]
+		beforeCount[before[i].Type] += 1
+		if index >= afterCount[before[i].Type] {
+			removed = append(removed, PatchOperation{Op: PatchRemoveComponent, Object: key, Type: before[i].Type, Index: index})
+		}
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		rtn = append(rtn, removed[i])
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i < len(after); i++ {
+		index := seen[after[i].Type]
+		seen[after[i].Type] += 1
+		if index >= beforeCount[after[i].Type] {
+			rtn = append(rtn, PatchOperation{Op: PatchAddComponent, Object: key, Type: after[i].Type, Index: index, Value: after[i].Data})
+			if after[i].Disabled {
+				rtn = append(rtn, PatchOperation{Op: PatchSetEnabled, Object: key, Type: after[i].Type, Index: index, Value: false})
+			}
+			continue
+		}
+		old := findPatchComponent(before, after[i].Type, index)
+		if old.Disabled != after[i].Disabled {
+			rtn = append(rtn, PatchOperation{Op: PatchSetEnabled, Object: key, Type: after[i].Type, Index: index, Value: !after[i].Disabled})
+		}
+		rtn = append(rtn, diffData(PatchOperation{Object: key, Type: after[i].Type, Index: index}, nil, old.Data, after[i].Data)...)
+	}
+
+	// The components that are kept, in their old order, followed by the added components
+	types := make([]string, 0, len(after))
+	kept := make(map[string]int)
+	for i := 0; i < len(before); i++ {
+		if kept[before[i].Type] < afterCount[before[i].Type] {
+			kept[before[i].Type] += 1
+			types = append(types, before[i].Type)
+		}
+	}
+	seen = make(map[string]int)
+	for i := 0; i < len(after); i++ {
+		if seen[after[i].Type] >= beforeCount[after[i].Type] {
+			types = append(types, after[i].Type)
+		}
+		seen[after[i].Type] += 1
+	}
+	expected := componentTypes(after)
+	if !equalTags(types, expected) {
+		rtn = append(rtn, PatchOperation{Op: PatchOrderComponents, Object: key, Types: expected})
+	}
+	return rtn
+}
+
+// componentTypes returns the type of each component, in order
+func componentTypes(components []ComponentTemplate) []string {
+	rtn := make([]string, len(components))
+	for i := 0; i < len(components); i++ {
+		rtn[i] = components[i].Type
+	}
+	return rtn
+}
+
+// diffData returns the operations that turn one data value into another; maps are compared key by key.
+func diffData(target PatchOperation, path []string, before interface{}, after interface{}) []PatchOperation {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if !beforeIsMap || !afterIsMap {
+		if reflect.DeepEqual(before, after) {
+			return nil
+		}
+		op := target
+		op.Value = after
+		if len(path) == 0 {
+			op.Op = PatchSetData
+		} else {
+			op.Op = PatchSetField
+			op.Field = path
+		}
+		return []PatchOperation{op}
+	}
+
+	keys := make([]string, 0, len(beforeMap)+len(afterMap))
+	for key := range beforeMap {
+		keys = append(keys, key)
+	}
+	for key := range afterMap {
+		if _, ok := beforeMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	rtn := make([]PatchOperation, 0)
+	for _, key := range keys {
+		field := append(append([]string{}, path...), key)
+		value, ok := afterMap[key]
+		if !ok {
+			op := target
+			op.Op = PatchRemoveField
+			op.Field = field
+			rtn = append(rtn, op)
+		} else if old, ok := beforeMap[key]; !ok {
+			op := target
+			op.Op = PatchSetField
+			op.Field = field
+			op.Value = value
+			rtn = append(rtn, op)
+		} else {
+			rtn = append(rtn, diffData(target, field, old, value)...)
+		}
+	}
+	return rtn
+}
+
+// equalTags checks if two sets of tags are the same, in the same order
+func equalTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyTags returns a copy of a set of tags; no tags is always nil.
+func copyTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return append([]string{}, tags...)
+}
+
+// findPatchComponent returns the index'th component of the given type, or nil
+func findPatchComponent(components []ComponentTemplate, componentType string, index int) *ComponentTemplate {
+	for i := 0; i < len(components); i++ {
+		if components[i].Type == componentType {
+			if index == 0 {
+				return &components[i]
+			}
+			index -= 1
+		}
+	}
+	return nil
+}
+
+// patchNode is a single object in a patchTree
+type patchNode struct {
+	key      string
+	template ObjectTemplate // The object template, without any child objects
+	parent   *patchNode
+	children []*patchNode
+}
+
+// patchTree is a mutable, keyed copy of an object template tree.
+type patchTree struct {
+	root  *patchNode
+	nodes map[string]*patchNode
+}
+
+// newPatchTree returns a deep copy of the template as a tree
+func newPatchTree(template *ObjectTemplate) *patchTree {
+	tree := &patchTree{nodes: make(map[string]*patchNode)}
+	tree.root = tree.add(template, "", nil)
+	return tree
+}
+
+// add copies a template and its children into the tree
+func (tree *patchTree) add(template *ObjectTemplate, key string, parent *patchNode) *patchNode {
+	node := &patchNode{key: key, parent: parent, children: make([]*patchNode, 0)}
+	node.template = copyPatchTemplate(template)
+	tree.nodes[key] = node
+	if parent != nil {
+		parent.children = append(parent.children, node)
+	}
+
+	for i := 0; i < len(template.Objects); i++ {
+		child := &template.Objects[i]
+		childKey := child.ID
+		if childKey == "" || tree.nodes[childKey] != nil {
+			childKey = key + "#" + strconv.Itoa(i)
+		}
+		tree.add(child, childKey, node)
+	}
+	return node
+}
+
+// copyPatchTemplate returns a deep copy of a template, without any child objects
+func copyPatchTemplate(template *ObjectTemplate) ObjectTemplate {
+	rtn := ObjectTemplate{Name: template.Name, ID: template.ID, Tags: copyTags(template.Tags), Inactive: template.Inactive}
+	if template.Components != nil {
+		rtn.Components = make([]ComponentTemplate, len(template.Components))
+		for i := 0; i < len(template.Components); i++ {
+			rtn.Components[i] = ComponentTemplate{Type: template.Components[i].Type, Data: copyPatchData(template.Components[i].Data), Disabled: template.Components[i].Disabled}
+		}
+	}
+	return rtn
+}
+
+// undo returns a function that puts back everything a patch operation may change in the tree, for
+// when the operation cannot be completed elsewhere; it must be called before the operation is applied.
+func (tree *patchTree) undo(op *PatchOperation) func() {
+	if op.Op == PatchAddObject {
+		if tree.nodes[op.Object] != nil {
+			return func() {}
+		}
+		return func() {
+			if node := tree.nodes[op.Object]; node != nil {
+				node.detach()
+				tree.forget(node)
+			}
+		}
+	}
+	node := tree.nodes[op.Object]
+	if node == nil {
+		return func() {}
+	}
+	parent := node.parent
+	index := -1
+	if parent != nil {
+		index = indexOfPatchNode(parent.children, node)
+	}
+	children := append([]*patchNode{}, node.children...)
+	template := copyPatchTemplate(&node.template)
+	return func() {
+		if parent != nil && (node.parent != parent || indexOfPatchNode(parent.children, node) != index) {
+			node.detach()
+			node.parent = parent
+			siblings := make([]*patchNode, 0, len(parent.children)+1)
+			siblings = append(siblings, parent.children[:index]...)
+			siblings = append(siblings, node)
+			parent.children = append(siblings, parent.children[index:]...)
+		}
+		node.children = children
+		node.template = template
+		tree.remember(node)
+	}
+}
+
+// indexOfPatchNode returns the index of a node in a set of nodes, or -1
+func indexOfPatchNode(nodes []*patchNode, node *patchNode) int {
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i] == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// walk invokes action on every node in the tree, parents before children
+func (tree *patchTree) walk(action func(node *patchNode)) {
+	stack := []*patchNode{tree.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		action(node)
+		for i := len(node.children) - 1; i >= 0; i-- {
+			stack = append(stack, node.children[i])
+		}
+	}
+}
+
+// template converts the tree back into an object template
+func (tree *patchTree) template() *ObjectTemplate {
+	return tree.root.toTemplate()
+}
+
+// toTemplate converts a node and its children back into an object template
+func (node *patchNode) toTemplate() *ObjectTemplate {
+	rtn := node.template
+	if len(node.children) > 0 {
+		rtn.Objects = make([]ObjectTemplate, len(node.children))
+		for i := 0; i < len(node.children); i++ {
+			rtn.Objects[i] = *node.children[i].toTemplate()
+		}
+	}
+	return &rtn
+}
+
+// childKeys returns the keys of the immediate children of a node
+func (node *patchNode) childKeys() []string {
+	rtn := make([]string, len(node.children))
+	for i := 0; i < len(node.children); i++ {
+		rtn[i] = node.children[i].key
+	}
+	return rtn
+}
+
+// detach removes a node from its parent
+func (node *patchNode) detach() {
+	if node.parent == nil {
+		return
+	}
+	siblings := node.parent.children
+	for i := 0; i < len(siblings); i++ {
+		if siblings[i] == node {
+			node.parent.children = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	node.parent = nil
+}
+
+// find returns the node for a key
+func (tree *patchTree) find(key string) (*patchNode, error) {
+	node := tree.nodes[key]
+	if node == nil {
+		return nil, errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No object '%s' in patch target", key))
+	}
+	return node, nil
+}
+
+// apply performs a single patch operation on the tree
+func (tree *patchTree) apply(op *PatchOperation) error {
+	node, err := tree.find(op.Object)
+	if op.Op == PatchAddObject {
+		if err == nil {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Object '%s' already exists in patch target", op.Object))
+		}
+		if op.Template == nil {
+			return errors.Fail(ErrNullValue{}, nil, "No template for added object")
+		}
+		parent, err := tree.find(op.Parent)
+		if err != nil {
+			return err
+		}
+		tree.add(&ObjectTemplate{Name: op.Template.Name, ID: op.Template.ID, Tags: op.Template.Tags, Inactive: op.Template.Inactive, Components: op.Template.Components}, op.Object, parent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case PatchRemoveObject:
+		if node == tree.root {
+			return errors.Fail(ErrBadObject{}, nil, "Cannot remove the root object")
+		}
+		node.detach()
+		tree.forget(node)
+	case PatchMoveObject:
+		parent, err := tree.find(op.Parent)
+		if err != nil {
+			return err
+		}
+		if node == tree.root || parent == node || parent.hasParent(node) {
+			return errors.Fail(ErrBadObject{}, nil, "Circular object references are not permitted")
+		}
+		node.detach()
+		node.parent = parent
+		parent.children = append(parent.children, node)
+	case PatchOrderObjects:
+		return node.order(op.Children)
+	case PatchRenameObject:
+		node.template.Name = op.Name
+	case PatchSetTags:
+		node.template.Tags = copyTags(op.Tags)
+	case PatchSetActive:
+		active, ok := op.Value.(bool)
+		if !ok {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid active state %v", op.Value))
+		}
+		node.template.Inactive = !active
+	case PatchAddComponent:
+		node.template.Components = append(node.template.Components, ComponentTemplate{Type: op.Type, Data: copyPatchData(op.Value)})
+	case PatchOrderComponents:
+		return node.orderComponents(op.Types)
+	case PatchRemoveComponent, PatchSetData, PatchSetField, PatchRemoveField, PatchSetEnabled:
+		return node.applyComponent(op)
+	default:
+		return errors.Fail(ErrNotSupported{}, nil, fmt.Sprintf("Unknown patch operation '%s'", op.Op))
+	}
+	return nil
+}
+
+// forget removes a node and its children from the key index
+func (tree *patchTree) forget(node *patchNode) {
+	delete(tree.nodes, node.key)
+	for i := 0; i < len(node.children); i++ {
+		tree.forget(node.children[i])
+	}
+}
+
+// remember adds a node and its children to the key index
+func (tree *patchTree) remember(node *patchNode) {
+	tree.nodes[node.key] = node
+	for i := 0; i < len(node.children); i++ {
+		tree.remember(node.children[i])
+	}
+}
+
+// hasParent checks if a node is a descendant of another node
+func (node *patchNode) hasParent(other *patchNode) bool {
+	for cursor := node.parent; cursor != nil; cursor = cursor.parent {
+		if cursor == other {
+			return true
+		}
+	}
+	return false
+}
+
+// order rearranges the children of a node; keys must name exactly the current children.
+func (node *patchNode) order(keys []string) error {
+	if len(keys) != len(node.children) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Object '%s' has %d children, not %d", node.key, len(node.children), len(keys)))
+	}
+	lookup := make(map[string]*patchNode, len(node.children))
+	for i := 0; i < len(node.children); i++ {
+		lookup[node.children[i].key] = node.children[i]
+	}
+	children := make([]*patchNode, len(keys))
+	for i := 0; i < len(keys); i++ {
+		child := lookup[keys[i]]
+		if child == nil {
+			return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("Object '%s' is not a child of '%s'", keys[i], node.key))
+		}
+		delete(lookup, keys[i])
+		children[i] = child
+	}
+	node.children = children
+	return nil
+}
+
+// orderComponents rearranges the components of a node so their types match types; components of the
+// same type keep their order, and types must name exactly the current components.
+func (node *patchNode) orderComponents(types []string) error {
+	components := node.template.Components
+	if len(types) != len(components) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Object '%s' has %d components, not %d", node.key, len(components), len(types)))
+	}
+	seen := make(map[string]int)
+	ordered := make([]ComponentTemplate, len(types))
+	for i := 0; i < len(types); i++ {
+		component := findPatchComponent(components, types[i], seen[types[i]])
+		if component == nil {
+			return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No component %s #%d on object '%s'", types[i], seen[types[i]], node.key))
+		}
+		seen[types[i]] += 1
+		ordered[i] = *component
+	}
+	node.template.Components = ordered
+	return nil
+}
+
+// applyComponent performs a component operation on a node
+func (node *patchNode) applyComponent(op *PatchOperation) error {
+	component := findPatchComponent(node.template.Components, op.Type, op.Index)
+	if component == nil {
+		return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No component %s #%d on object '%s'", op.Type, op.Index, node.key))
+	}
+
+	switch op.Op {
+	case PatchRemoveComponent:
+		for i := 0; i < len(node.template.Components); i++ {
+			if &node.template.Components[i] == component {
+				node.template.Components = append(node.template.Components[:i], node.template.Components[i+1:]...)
+				break
+			}
+		}
+		if len(node.template.Components) == 0 {
+			node.template.Components = nil
+		}
+	case PatchSetData:
+		component.Data = copyPatchData(op.Value)
+	case PatchSetEnabled:
+		enabled, ok := op.Value.(bool)
+		if !ok {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid enabled state %v", op.Value))
+		}
+		component.Disabled = !enabled
+	case PatchSetField, PatchRemoveField:
+		if len(op.Field) == 0 {
+			return errors.Fail(ErrBadValue{}, nil, "No field given for component data")
+		}
+		data, ok := component.Data.(map[string]interface{})
+		if !ok {
+			if op.Op == PatchRemoveField {
+				return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("Component %s has no field %v", op.Type, op.Field))
+			}
+			data = make(map[string]interface{})
+			component.Data = data
+		}
+		for i := 0; i < len(op.Field)-1; i++ {
+			next, ok := data[op.Field[i]].(map[string]interface{})
+			if !ok {
+				if op.Op == PatchRemoveField {
+					return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("Component %s has no field %v", op.Type, op.Field))
+				}
+				next = make(map[string]interface{})
+				data[op.Field[i]] = next
+			}
+			data = next
+		}
+		last := op.Field[len(op.Field)-1]
+		if op.Op == PatchRemoveField {
+			delete(data, last)
+		} else {
+			data[last] = copyPatchData(op.Value)
+		}
+	}
+	return nil
+}
+
+// copyPatchData returns a deep copy of component data as plain json values.
+// Data that cannot be converted is used as is.
+func copyPatchData(data interface{}) interface{} {
+	rtn, err := plainTemplateData(data)
+	if err != nil {
+		return data
+	}
+	return rtn
+}
@@ -0,0 +1,210 @@
+package component_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"ntoolkit/assert"
+	c "ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+func patchFixture() (*c.ObjectFactory, *c.Object) {
+	factory := c.NewObjectFactory()
+	factory.Register(&FakeComponent{})
+	factory.Register(&FakeConfiguredComponent{})
+
+	root := c.NewObject("Root")
+	for _, name := range []string{"A", "B", "C"} {
+		child := c.NewObject(name)
+		child.AddComponent(&FakeComponent{Id: name})
+		root.AddObject(child)
+	}
+	config := &FakeConfiguredComponent{}
+	config.Data.Items = []FakeConfiguredComponentItem{{Id: "1", Count: 1}}
+	root.AddComponent(config)
+	return factory, root
+}
+
+func TestDiffIdenticalTemplates(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory, root := patchFixture()
+		template, err := factory.Serialize(root)
+		T.Assert(err == nil)
+
+		patch := c.DiffTemplates(template, template)
+		T.Assert(len(patch.Operations) == 0)
+	})
+}
+
+func TestDiffAndApplyPatch(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory, root := patchFixture()
+		before, err := factory.Serialize(root)
+		T.Assert(err == nil)
+
+		a, _ := root.GetObject("A")
+		b, _ := root.GetObject("B")
+		cc, _ := root.GetObject("C")
+
+		// Move C under A, remove B, add D with a child, rename A and change component data
+		a.AddObject(cc)
+		root.RemoveObject(b)
+		d := c.NewObject("D")
+		d.AddObject(c.NewObject("E"))
+		d.AddComponent(&FakeComponent{Id: "D"})
+		root.AddObject(d)
+		a.Rename("A2")
+
+		var fake *FakeComponent
+		T.Assert(cc.Find(&fake) == nil)
+		fake.Count = 10
+
+		var config *FakeConfiguredComponent
+		T.Assert(root.Find(&config) == nil)
+		config.Data.Items = append(config.Data.Items, FakeConfiguredComponentItem{Id: "2", Count: 2})
+
+		after, err := factory.Serialize(root)
+		T.Assert(err == nil)
+
+		patch := c.DiffTemplates(before, after)
+		T.Assert(len(patch.Operations) > 0)
+
+		raw, err := c.PatchAsJson(&patch)
+		T.Assert(err == nil)
+		loaded, err := c.PatchFromJson(string(raw))
+		T.Assert(err == nil)
+
+		patched, err := c.ApplyPatch(before, *loaded)
+		T.Assert(err == nil)
+
+		expected, _ := c.ObjectTemplateAsJson(after)
+		actual, _ := c.ObjectTemplateAsJson(patched)
+		T.Assert(string(expected) == string(actual))
+
+		// The original template is unchanged
+		original, _ := c.ObjectTemplateAsJson(before)
+		T.Assert(len(c.DiffTemplates(before, before).Operations) == 0)
+		T.Assert(string(original) != string(actual))
+	})
+}
+
+func TestDiffReorderedChildren(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory, root := patchFixture()
+		before, _ := factory.Serialize(root)
+
+		a, _ := root.GetObject("A")
+		root.RemoveObject(a)
+		root.AddObject(a)
+		after, _ := factory.Serialize(root)
+
+		patch := c.DiffTemplates(before, after)
+		T.Assert(len(patch.Operations) == 1)
+		T.Assert(patch.Operations[0].Op == c.PatchOrderObjects)
+
+		patched, err := c.ApplyPatch(before, patch)
+		T.Assert(err == nil)
+		T.Assert(patched.Objects[2].Name == "A")
+	})
+}
+
+// componentTemplates returns templates for the given types, each with its own data
+func componentTemplates(types ...string) []c.ComponentTemplate {
+	rtn := make([]c.ComponentTemplate, len(types))
+	for i := 0; i < len(types); i++ {
+		rtn[i] = c.ComponentTemplate{Type: types[i], Data: map[string]interface{}{"Id": types[i] + fmt.Sprint(i)}}
+	}
+	return rtn
+}
+
+// sameComponents checks if two sets of component templates have the same types, data and state, in order
+func sameComponents(a []c.ComponentTemplate, b []c.ComponentTemplate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i].Type != b[i].Type || a[i].Disabled != b[i].Disabled || !reflect.DeepEqual(a[i].Data, b[i].Data) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiffReorderedComponents(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		before := &c.ObjectTemplate{Components: componentTemplates("A", "B")}
+		after := &c.ObjectTemplate{Components: []c.ComponentTemplate{before.Components[1], before.Components[0]}}
+		patch := c.DiffTemplates(before, after)
+		T.Assert(len(patch.Operations) == 1)
+		T.Assert(patch.Operations[0].Op == c.PatchOrderComponents)
+		patched, err := c.ApplyPatch(before, patch)
+		T.Assert(err == nil)
+		T.Assert(sameComponents(patched.Components, after.Components))
+
+		// A component inserted in the middle stays in the middle
+		before = &c.ObjectTemplate{Components: componentTemplates("A", "B", "A")}
+		inserted := c.ComponentTemplate{Type: "C", Data: map[string]interface{}{"Id": "New"}}
+		after = &c.ObjectTemplate{Components: []c.ComponentTemplate{before.Components[0], inserted, before.Components[1], before.Components[2]}}
+		patched, err = c.ApplyPatch(before, c.DiffTemplates(before, after))
+		T.Assert(err == nil)
+		T.Assert(sameComponents(patched.Components, after.Components))
+	})
+}
+
+func TestDiffComponentsRoundTrip(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		random := rand.New(rand.NewSource(1))
+		randomComponents := func() []c.ComponentTemplate {
+			rtn := make([]c.ComponentTemplate, random.Intn(6))
+			for i := 0; i < len(rtn); i++ {
+				rtn[i] = c.ComponentTemplate{
+					Type:     []string{"A", "B", "C"}[random.Intn(3)],
+					Data:     map[string]interface{}{"Value": float64(random.Intn(3))},
+					Disabled: random.Intn(4) == 0}
+			}
+			return rtn
+		}
+		for i := 0; i < 500; i++ {
+			before := &c.ObjectTemplate{Components: randomComponents()}
+			after := &c.ObjectTemplate{Components: randomComponents()}
+			patched, err := c.ApplyPatch(before, c.DiffTemplates(before, after))
+			T.Assert(err == nil)
+			T.Assert(sameComponents(patched.Components, after.Components))
+		}
+	})
+}
+
+func TestDiffComponentFields(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		before := &c.ObjectTemplate{Components: []c.ComponentTemplate{
+			{Type: "Config", Data: map[string]interface{}{"Speed": 1.0, "Limits": map[string]interface{}{"Min": 0.0, "Max": 5.0}}}}}
+		after := &c.ObjectTemplate{Components: []c.ComponentTemplate{
+			{Type: "Config", Data: map[string]interface{}{"Limits": map[string]interface{}{"Min": 0.0, "Max": 10.0}, "Name": "Fast"}}}}
+
+		patch := c.DiffTemplates(before, after)
+		T.Assert(len(patch.Operations) == 3)
+		T.Assert(patch.Operations[0].Op == c.PatchSetField)
+		T.Assert(patch.Operations[0].Field[0] == "Limits" && patch.Operations[0].Field[1] == "Max")
+		T.Assert(patch.Operations[1].Op == c.PatchSetField)
+		T.Assert(patch.Operations[2].Op == c.PatchRemoveField)
+
+		patched, err := c.ApplyPatch(before, patch)
+		T.Assert(err == nil)
+		data := patched.Components[0].Data.(map[string]interface{})
+		T.Assert(data["Name"] == "Fast")
+		T.Assert(data["Limits"].(map[string]interface{})["Max"] == 10.0)
+		_, hasSpeed := data["Speed"]
+		T.Assert(!hasSpeed)
+	})
+}
+
+func TestApplyPatchUnknownObject(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		patch := c.Patch{Operations: []c.PatchOperation{{Op: c.PatchRenameObject, Object: "missing", Name: "Nope"}}}
+		_, err := c.ApplyPatch(&c.ObjectTemplate{}, patch)
+		T.Assert(errors.Is(err, c.ErrNoMatch{}))
+	})
+}
@@ -0,0 +1,358 @@
+package component
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ntoolkit/errors"
+	"ntoolkit/iter"
+)
+
+// ObjectQuery is a parsed path query over an object tree.
+//
+// A query is a sequence of segments separated by '/'. Each segment matches the children of the
+// objects matched by the segments before it:
+//
+//	Weapon      a child named exactly "Weapon"
+//	Enemy*      a name glob; '*' matches any run of characters and '?' any single character
+//	**          any number of levels, including none; as the last segment, every descendant
+//	[Health]    a component predicate; the object must have a component of this type, given either
+//	            by its short name or by its full type name as used in templates
+//	[#boss]     a tag predicate; the object must have the tag "boss"
+//	[2]         an index predicate; the third of the children still matched at this point
+//
+// Predicates follow the name and apply in order, eg. "Enemies/*[Health][#boss]" or "Enemy[2]" for
+// the third child named "Enemy". A segment of only predicates matches any name. A leading '/'
+// starts from the root of the tree instead of the object itself. A '\' escapes the next character
+// of a name, eg. "Ammo\*".
+type ObjectQuery struct {
+	query    string
+	absolute bool
+	segments []querySegment
+}
+
+// querySegment is a single '/' separated part of a query
+type querySegment struct {
+	recursive  bool             // This is a '**' segment
+	literal    bool             // The pattern has no wildcards and is the name itself
+	pattern    []rune           // The name pattern, with escapes if it is not literal
+	predicates []queryPredicate // The filters applied to the children that match the name
+}
+
+// queryPredicateKind is the kind of filter in a queryPredicate
+type queryPredicateKind int
+
+const (
+	queryComponent queryPredicateKind = iota
+	queryTag
+	queryIndex
+)
+
+// queryPredicate is a single '[...]' filter on a segment
+type queryPredicate struct {
+	kind  queryPredicateKind
+	value string // The component type or tag
+	index int    // The index to pick
+}
+
+// ParseQuery parses a query; see ObjectQuery for the syntax.
+func ParseQuery(query string) (*ObjectQuery, error) {
+	rtn := &ObjectQuery{query: query, segments: make([]querySegment, 0)}
+	src := []rune(query)
+	if len(src) == 0 {
+		return nil, rtn.fail(0, "Query is empty")
+	}
+
+	pos := 0
+	if src[0] == '/' {
+		rtn.absolute = true
+		pos = 1
+		if len(src) == 1 {
+			return rtn, nil
+		}
+	}
+
+	for {
+		segment, next, err := rtn.parseSegment(src, pos)
+		if err != nil {
+			return nil, err
+		}
+		rtn.segments = append(rtn.segments, segment)
+		if next == len(src) {
+			return rtn, nil
+		}
+		pos = next + 1
+		if pos == len(src) {
+			return nil, rtn.fail(pos, "Query cannot end with '/'")
+		}
+	}
+}
+
+// parseSegment parses the segment starting at pos, and returns the offset of the '/' or the end of
+// the query that follows it.
+func (query *ObjectQuery) parseSegment(src []rune, pos int) (querySegment, int, error) {
+	segment := querySegment{literal: true, pattern: make([]rune, 0)}
+	start := pos
+
+	// The name pattern
+	for pos < len(src) && src[pos] != '/' && src[pos] != '[' {
+		switch src[pos] {
+		case '\\':
+			if pos+1 == len(src) {
+				return segment, pos, query.fail(pos, "Nothing to escape")
+			}
+			segment.pattern = append(segment.pattern, src[pos], src[pos+1])
+			pos += 2
+			continue
+		case ']':
+			return segment, pos, query.fail(pos, "Unexpected ']'")
+		case '*', '?':
+			segment.literal = false
+		}
+		segment.pattern = append(segment.pattern, src[pos])
+		pos += 1
+	}
+
+	if string(segment.pattern) == "**" {
+		segment.recursive = true
+		if pos < len(src) && src[pos] == '[' {
+			return segment, pos, query.fail(pos, "A '**' segment cannot have predicates")
+		}
+		return segment, pos, nil
+	}
+
+	// The predicates
+	for pos < len(src) && src[pos] == '[' {
+		end := pos + 1
+		for end < len(src) && src[end] != ']' {
+			end += 1
+		}
+		if end == len(src) {
+			return segment, pos, query.fail(pos, "Unterminated '['")
+		}
+		predicate := strings.TrimSpace(string(src[pos+1 : end]))
+		if strings.HasPrefix(predicate, "#") {
+			tag := strings.TrimSpace(predicate[1:])
+			if tag == "" {
+				return segment, pos, query.fail(pos, "Missing tag name")
+			}
+			segment.predicates = append(segment.predicates, queryPredicate{kind: queryTag, value: tag})
+		} else if predicate == "" {
+			return segment, pos, query.fail(pos, "Missing component type")
+		} else if predicate[0] >= '0' && predicate[0] <= '9' {
+			index, err := strconv.Atoi(predicate)
+			if err != nil {
+				return segment, pos, query.fail(pos, fmt.Sprintf("Invalid index '%s'", predicate))
+			}
+			segment.predicates = append(segment.predicates, queryPredicate{kind: queryIndex, index: index})
+		} else {
+			segment.predicates = append(segment.predicates, queryPredicate{kind: queryComponent, value: predicate})
+		}
+		pos = end + 1
+	}
+	if pos < len(src) && src[pos] != '/' {
+		return segment, pos, query.fail(pos, fmt.Sprintf("Unexpected '%c' after predicates", src[pos]))
+	}
+
+	if len(segment.pattern) == 0 {
+		if len(segment.predicates) == 0 {
+			return segment, start, query.fail(start, "Empty path segment")
+		}
+		segment.literal = false
+		segment.pattern = []rune{'*'}
+	}
+	if segment.literal {
+		segment.pattern = unescapeQuery(segment.pattern)
+	}
+	return segment, pos, nil
+}
+
+// fail returns an ErrBadValue for a position in the query
+func (query *ObjectQuery) fail(offset int, message string) error {
+	return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid query '%s' at offset %d: %s", query.query, offset, message))
+}
+
+// unescapeQuery removes the escapes from a literal name
+func unescapeQuery(pattern []rune) []rune {
+	rtn := make([]rune, 0, len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			i += 1
+		}
+		rtn = append(rtn, pattern[i])
+	}
+	return rtn
+}
+
+// String returns the query text
+func (query *ObjectQuery) String() string {
+	return query.query
+}
+
+// Match returns an iterator over the objects the query matches, starting from the given object.
+// Each object is returned once, in the order the tree was searched.
+func (query *ObjectQuery) Match(object *Object) iter.Iter {
+	if object == nil {
+		return fromObjectList(nil, errors.Fail(ErrNullValue{}, nil, "Invalid root object"))
+	}
+
+	current := []*Object{object}
+	if query.absolute {
+		current[0] = object.Root()
+	}
+	for i := 0; i < len(query.segments) && len(current) > 0; i++ {
+		segment := &query.segments[i]
+		seen := make(map[*Object]bool)
+		next := make([]*Object, 0)
+		if segment.recursive {
+			last := i == len(query.segments)-1
+			for j := 0; j < len(current); j++ {
+				collectDescendants(current[j], !last, seen, &next)
+			}
+		} else {
+			for j := 0; j < len(current); j++ {
+				matches := segment.match(current[j].children)
+				for k := 0; k < len(matches); k++ {
+					if !seen[matches[k]] {
+						seen[matches[k]] = true
+						next = append(next, matches[k])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return fromObjectList(current, nil)
+}
+
+// collectDescendants appends every descendant of the object, depth first, and the object itself if
+// self is set; objects already seen are skipped.
+func collectDescendants(object *Object, self bool, seen map[*Object]bool, output *[]*Object) {
+	if self {
+		if seen[object] {
+			return
+		}
+		seen[object] = true
+		*output = append(*output, object)
+	}
+	children := object.children
+	for i := 0; i < len(children); i++ {
+		collectDescendants(children[i], true, seen, output)
+	}
+}
+
+// match returns the children of an object that match the name of a segment and its predicates
+func (segment *querySegment) match(children []*Object) []*Object {
+	rtn := make([]*Object, 0)
+	for i := 0; i < len(children); i++ {
+		if segment.literal {
+			if children[i].name == string(segment.pattern) {
+				rtn = append(rtn, children[i])
+			}
+		} else if matchQueryName(segment.pattern, []rune(children[i].name)) {
+			rtn = append(rtn, children[i])
+		}
+	}
+	for i := 0; i < len(segment.predicates) && len(rtn) > 0; i++ {
+		rtn = segment.predicates[i].filter(rtn)
+	}
+	return rtn
+}
+
+// filter returns the objects that pass a predicate
+func (predicate *queryPredicate) filter(objects []*Object) []*Object {
+	if predicate.kind == queryIndex {
+		if predicate.index >= len(objects) {
+			return nil
+		}
+		return objects[predicate.index : predicate.index+1]
+	}
+	rtn := make([]*Object, 0, len(objects))
+	for i := 0; i < len(objects); i++ {
+		if predicate.kind == queryTag && objects[i].HasTag(predicate.value) {
+			rtn = append(rtn, objects[i])
+		} else if predicate.kind == queryComponent && hasComponentNamed(objects[i], predicate.value) {
+			rtn = append(rtn, objects[i])
+		}
+	}
+	return rtn
+}
+
+// matchQueryName matches a name against a glob pattern with escapes
+func matchQueryName(pattern []rune, name []rune) bool {
+	p, n := 0, 0
+	starP, starN := -1, 0
+	for n < len(name) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starN = p, n
+				p += 1
+				continue
+			case '?':
+				p += 1
+				n += 1
+				continue
+			case '\\':
+				if pattern[p+1] == name[n] {
+					p += 2
+					n += 1
+					continue
+				}
+			default:
+				if pattern[p] == name[n] {
+					p += 1
+					n += 1
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the last '*' match one more character and try again
+		starN += 1
+		p, n = starP+1, starN
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p += 1
+	}
+	return p == len(pattern)
+}
+
+// hasComponentNamed checks if an object has a component with the given full or short type name
+func hasComponentNamed(object *Object, name string) bool {
+	components := object.components
+	for i := 0; i < len(components); i++ {
+		full := typeName(components[i].Type)
+		if full == name || shortTypeName(full) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shortTypeName returns the bare name of a type from its full type name, eg. "Health" for "*game/units.Health"
+func shortTypeName(full string) string {
+	full = strings.TrimLeft(full, "*")
+	if offset := strings.LastIndex(full, "."); offset >= 0 {
+		return full[offset+1:]
+	}
+	return full
+}
+
+// Query returns an iterator over the objects matched by a query, starting from this object.
+// See ObjectQuery for the syntax; an invalid query returns an iterator that yields the parse error.
+func (o *Object) Query(query string) iter.Iter {
+	parsed, err := ParseQuery(query)
+	if err != nil {
+		return fromObjectList(nil, err)
+	}
+	return parsed.Match(o)
+}
+
+// Query returns an iterator over the objects matched by a query, starting from the runtime root.
+func (runtime *Runtime) Query(query string) iter.Iter {
+	return runtime.root.Query(query)
+}
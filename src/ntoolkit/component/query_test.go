@@ -0,0 +1,180 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"ntoolkit/iter"
+	"testing"
+)
+
+func queryFixture() (*component.Runtime, map[string]*component.Object) {
+	runtime := component.NewRuntime(component.Config{})
+	objects := map[string]*component.Object{
+		"Root":    component.NewObject("Root"),
+		"Enemies": component.NewObject("Enemies"),
+		"Orc":     component.NewObject("Orc"),
+		"Goblin":  component.NewObject("Goblin"),
+		"Ogre":    component.NewObject("Ogre"),
+		"Sword":   component.NewObject("Weapon"),
+		"Axe":     component.NewObject("Weapon"),
+		"Club":    component.NewObject("Weapon"),
+		"Ammo":    component.NewObject("Ammo*"),
+	}
+	runtime.Root().AddObject(objects["Root"])
+	objects["Root"].AddObject(objects["Enemies"])
+	objects["Enemies"].AddObject(objects["Orc"])
+	objects["Enemies"].AddObject(objects["Goblin"])
+	objects["Enemies"].AddObject(objects["Ogre"])
+	objects["Orc"].AddObject(objects["Axe"])
+	objects["Goblin"].AddObject(objects["Sword"])
+	objects["Ogre"].AddObject(objects["Club"])
+	objects["Ogre"].AddObject(objects["Ammo"])
+
+	objects["Orc"].AddComponent(&FakeComponent{})
+	objects["Ogre"].AddComponent(&FakeComponent{})
+	objects["Ogre"].AddTag("boss")
+	objects["Axe"].AddTag("sharp")
+	objects["Sword"].AddTag("sharp")
+	return runtime, objects
+}
+
+func queryResults(T *assert.T, results iter.Iter) []*component.Object {
+	values, err := iter.Collect(results)
+	T.Assert(err == nil)
+	rtn := make([]*component.Object, len(values))
+	for i := 0; i < len(values); i++ {
+		rtn[i] = values[i].(*component.Object)
+	}
+	return rtn
+}
+
+func TestQueryPaths(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime, objects := queryFixture()
+
+		results := queryResults(T, runtime.Query("Root/Enemies/*/Weapon"))
+		T.Assert(len(results) == 3)
+		T.Assert(results[0] == objects["Axe"])
+		T.Assert(results[1] == objects["Sword"])
+		T.Assert(results[2] == objects["Club"])
+
+		results = queryResults(T, objects["Enemies"].Query("O*"))
+		T.Assert(len(results) == 2)
+		T.Assert(results[0] == objects["Orc"])
+		T.Assert(results[1] == objects["Ogre"])
+
+		results = queryResults(T, objects["Enemies"].Query("?rc"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Orc"])
+
+		results = queryResults(T, objects["Sword"].Query("/Root/Enemies"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Enemies"])
+
+		results = queryResults(T, objects["Ogre"].Query("Ammo\\*"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Ammo"])
+
+		results = queryResults(T, runtime.Query("Root/Missing/*"))
+		T.Assert(len(results) == 0)
+	})
+}
+
+func TestQueryRecursive(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime, objects := queryFixture()
+
+		results := queryResults(T, runtime.Query("**/Weapon"))
+		T.Assert(len(results) == 3)
+
+		results = queryResults(T, objects["Root"].Query("**/Enemies"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Enemies"])
+
+		results = queryResults(T, objects["Enemies"].Query("**"))
+		T.Assert(len(results) == 7)
+		T.Assert(results[0] == objects["Orc"])
+		T.Assert(results[1] == objects["Axe"])
+
+		results = queryResults(T, runtime.Query("**/**/Weapon"))
+		T.Assert(len(results) == 3)
+	})
+}
+
+func TestQueryPredicates(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime, objects := queryFixture()
+
+		results := queryResults(T, runtime.Query("**/*[FakeComponent]"))
+		T.Assert(len(results) == 2)
+		T.Assert(results[0] == objects["Orc"])
+		T.Assert(results[1] == objects["Ogre"])
+
+		results = queryResults(T, runtime.Query("**/[*ntoolkit/component_test.FakeComponent][#boss]"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Ogre"])
+
+		results = queryResults(T, runtime.Query("Root/Enemies/[#boss]/Weapon"))
+		T.Assert(len(results) == 1)
+		T.Assert(results[0] == objects["Club"])
+
+		results = queryResults(T, runtime.Query("**/Weapon[#sharp]"))
+		T.Assert(len(results) == 2)
+		T.Assert(results[0] == objects["Axe"])
+		T.Assert(results[1] == objects["Sword"])
+	})
+}
+
+func TestQueryErrors(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime, _ := queryFixture()
+		for _, query := range []string{"", "Root//Enemies", "Root/", "Root[Health", "**[Health]", "Root[]", "Root[#]", "Root[Health]x", "Ammo\\"} {
+			_, err := component.ParseQuery(query)
+			T.Assert(errors.Is(err, component.ErrBadValue{}))
+
+			_, err = runtime.Query(query).Next()
+			T.Assert(errors.Is(err, component.ErrBadValue{}))
+		}
+	})
+}
+
+func TestTagsAreSerialized(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := component.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+
+		obj := component.NewObject("Orc")
+		obj.AddTag("enemy")
+		obj.AddTag("boss")
+		obj.AddTag("enemy")
+		T.Assert(len(obj.Tags()) == 2)
+
+		template, err := factory.Serialize(obj)
+		T.Assert(err == nil)
+		T.Assert(len(template.Tags) == 2)
+
+		raw, err := component.ObjectTemplateAsYaml(template)
+		T.Assert(err == nil)
+		template, err = component.ObjectTemplateFromYaml(string(raw))
+		T.Assert(err == nil)
+
+		raw, err = component.ObjectTemplateAsToml(template)
+		T.Assert(err == nil)
+		template, err = component.ObjectTemplateFromToml(string(raw))
+		T.Assert(err == nil)
+
+		clone, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+		T.Assert(clone.HasTag("enemy"))
+		T.Assert(clone.HasTag("boss"))
+
+		clone.RemoveTag("enemy")
+		T.Assert(!clone.HasTag("enemy"))
+		T.Assert(obj.HasTag("enemy"))
+
+		patch := component.DiffTemplates(template, &component.ObjectTemplate{Name: "Orc", ID: template.ID})
+		T.Assert(len(patch.Operations) == 1)
+		T.Assert(patch.Operations[0].Op == component.PatchSetTags)
+	})
+}
@@ -0,0 +1,454 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"ntoolkit/errors"
+)
+
+// Replicated marks the object it is attached to as replicated to clients by a ReplicationServer.
+// Objects without this component are not sent to clients, but their replicated children are;
+// they appear as children of the nearest replicated parent instead.
+type Replicated struct{}
+
+func (r *Replicated) Type() reflect.Type {
+	return reflect.TypeOf(r)
+}
+
+func (r *Replicated) New() Component {
+	return &Replicated{}
+}
+
+// ReplicationMessage is a single update sent from a replication server to a client.
+type ReplicationMessage struct {
+	Tick  int   // The server tick that produced this message
+	Patch Patch // The changes since the last message to this client
+}
+
+// ReplicationServer sends the state of replicated objects in a runtime to a set of clients.
+type ReplicationServer struct {
+	runtime *Runtime
+	clients []*ReplicationPeer
+	tick    int
+	lock    *sync.Mutex
+}
+
+// ReplicationPeer is a single client connected to a replication server.
+// A peer only receives the replicated objects inside the subtrees it watches.
+type ReplicationPeer struct {
+	conn     io.ReadWriter
+	encoder  *json.Encoder
+	interest []*Object       // The roots of the watched subtrees
+	last     *ObjectTemplate // The state last sent to the client
+	lock     *sync.Mutex
+}
+
+// ReplicationClient applies the updates from a replication server to a local runtime.
+// Replicated objects are created as children of the client runtime's root, with the same ids as on the server.
+type ReplicationClient struct {
+	runtime *Runtime
+	decoder *json.Decoder
+	mirror  *patchTree         // The last state received from the server
+	objects map[string]*Object // The local objects for each replicated id
+	tick    int
+}
+
+// NewReplicationServer returns a replication server for the runtime.
+func NewReplicationServer(runtime *Runtime) *ReplicationServer {
+	runtime.factory.Register(&Replicated{})
+	return &ReplicationServer{
+		runtime: runtime,
+		clients: make([]*ReplicationPeer, 0),
+		lock:    &sync.Mutex{}}
+}
+
+// Connect adds a client; it receives nothing until it watches a subtree.
+func (server *ReplicationServer) Connect(conn io.ReadWriter) *ReplicationPeer {
+	peer := &ReplicationPeer{
+		conn:     conn,
+		encoder:  json.NewEncoder(conn),
+		interest: make([]*Object, 0),
+		last:     &ObjectTemplate{},
+		lock:     &sync.Mutex{}}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.clients = append(server.clients, peer)
+	return peer
+}
+
+// Disconnect removes a client; the connection itself is not closed.
+func (server *ReplicationServer) Disconnect(peer *ReplicationPeer) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for i := 0; i < len(server.clients); i++ {
+		if server.clients[i] == peer {
+			server.clients = append(server.clients[:i], server.clients[i+1:]...)
+			return
+		}
+	}
+}
+
+// Watch adds a subtree to the objects replicated to this client.
+func (peer *ReplicationPeer) Watch(object *Object) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	for i := 0; i < len(peer.interest); i++ {
+		if peer.interest[i] == object {
+			return
+		}
+	}
+	peer.interest = append(peer.interest, object)
+}
+
+// Unwatch removes a subtree from the objects replicated to this client.
+// The client removes the objects on the next tick.
+func (peer *ReplicationPeer) Unwatch(object *Object) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	for i := 0; i < len(peer.interest); i++ {
+		if peer.interest[i] == object {
+			peer.interest = append(peer.interest[:i], peer.interest[i+1:]...)
+			return
+		}
+	}
+}
+
+// Tick sends every client the changes to its replicated objects since the last tick.
+// The state is captured between frames; do not call it from a component or a scheduled task.
+// Clients that fail to receive their update are disconnected, and the first error is returned.
+func (server *ReplicationServer) Tick() error {
+	server.lock.Lock()
+	clients := append([]*ReplicationPeer{}, server.clients...)
+	server.tick += 1
+	tick := server.tick
+	server.lock.Unlock()
+
+	// Capture every view between frames, then send them once the runtime is free again
+	views := make([]*ObjectTemplate, len(clients))
+	server.runtime.updateLock.Lock()
+	for i := 0; i < len(clients); i++ {
+		view, err := server.view(clients[i])
+		if err != nil {
+			server.runtime.updateLock.Unlock()
+			return err
+		}
+		views[i] = view
+	}
+	server.runtime.updateLock.Unlock()
+
+	var rtn error
+	for i := 0; i < len(clients); i++ {
+		patch := DiffTemplates(clients[i].last, views[i])
+		if len(patch.Operations) == 0 {
+			continue
+		}
+		if err := clients[i].encoder.Encode(&ReplicationMessage{Tick: tick, Patch: patch}); err != nil {
+			server.Disconnect(clients[i])
+			if rtn == nil {
+				rtn = errors.Fail(ErrBadValue{}, err, "Failed to send replication update")
+			}
+			continue
+		}
+		clients[i].last = views[i]
+	}
+	return rtn
+}
+
+// view returns the replicated objects a client can see as a template; the template root stands
+// in for the client's runtime root.
+func (server *ReplicationServer) view(peer *ReplicationPeer) (*ObjectTemplate, error) {
+	peer.lock.Lock()
+	interest := append([]*Object{}, peer.interest...)
+	peer.lock.Unlock()
+
+	rtn := &ObjectTemplate{}
+	for i := 0; i < len(interest); i++ {
+		root := interest[i]
+		if root.Root() != server.runtime.root || hasInterestParent(root, interest) {
+			continue
+		}
+		objects, err := server.collect(root)
+		if err != nil {
+			return nil, err
+		}
+		rtn.Objects = append(rtn.Objects, objects...)
+	}
+	return rtn, nil
+}
+
+// hasInterestParent checks if a subtree is already covered by another watched subtree
+func hasInterestParent(object *Object, interest []*Object) bool {
+	for i := 0; i < len(interest); i++ {
+		if interest[i] != object && object.HasParent(interest[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// collect returns the templates for the replicated objects in a subtree
+func (server *ReplicationServer) collect(object *Object) ([]ObjectTemplate, error) {
+	children := make([]ObjectTemplate, 0)
+	for i := 0; i < len(object.children); i++ {
+		objects, err := server.collect(object.children[i])
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, objects...)
+	}
+
+	replicated := false
+	template := ObjectTemplate{Name: object.name, ID: object.id, Tags: copyTags(object.tags), Inactive: object.inactive}
+	for i := 0; i < len(object.components); i++ {
+		if _, ok := object.components[i].Component.(*Replicated); ok {
+			replicated = true
+			continue
+		}
+		component, err := server.runtime.factory.serializeComponent(object.components[i])
+		if err != nil {
+			return nil, err
+		}
+		template.Components = append(template.Components, *component)
+	}
+	if !replicated {
+		return children, nil
+	}
+	if len(children) > 0 {
+		template.Objects = children
+	}
+	return []ObjectTemplate{template}, nil
+}
+
+// NewReplicationClient returns a client that reads updates from the connection.
+// Every replicated component type must be registered with the runtime's factory.
+func NewReplicationClient(runtime *Runtime, conn io.ReadWriter) *ReplicationClient {
+	return &ReplicationClient{
+		runtime: runtime,
+		decoder: json.NewDecoder(conn),
+		mirror:  newPatchTree(&ObjectTemplate{}),
+		objects: make(map[string]*Object)}
+}
+
+// Tick returns the server tick of the last update received.
+func (client *ReplicationClient) Tick() int {
+	return client.tick
+}
+
+// Receive blocks until the next update arrives and then applies it between frames.
+// It returns io.EOF when the connection is closed.
+func (client *ReplicationClient) Receive() error {
+	var message ReplicationMessage
+	if err := client.decoder.Decode(&message); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return errors.Fail(ErrBadValue{}, err, "Failed to read replication update")
+	}
+
+	client.runtime.updateLock.Lock()
+	defer client.runtime.updateLock.Unlock()
+	for i := 0; i < len(message.Patch.Operations); i++ {
+		if err := client.apply(&message.Patch.Operations[i]); err != nil {
+			return err
+		}
+	}
+	client.tick = message.Tick
+	return nil
+}
+
+// object returns the local object for a replicated id; "" is the runtime root.
+func (client *ReplicationClient) object(id string) (*Object, error) {
+	if id == "" {
+		return client.runtime.root, nil
+	}
+	obj := client.objects[id]
+	if obj == nil {
+		return nil, errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No replicated object '%s'", id))
+	}
+	return obj, nil
+}
+
+// apply performs a single patch operation on the mirror and then on the local objects. If the local
+// objects cannot be changed the mirror is put back, so the two always agree.
+func (client *ReplicationClient) apply(op *PatchOperation) error {
+	undo := client.mirror.undo(op)
+	if err := client.mirror.apply(op); err != nil {
+		undo()
+		return err
+	}
+	if err := client.applyLocal(op); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// applyLocal performs a patch operation that has been applied to the mirror on the local objects.
+// Replicated objects keep the names they have on the server, so the name policy is not applied.
+func (client *ReplicationClient) applyLocal(op *PatchOperation) error {
+	if op.Op == PatchAddObject {
+		parent, err := client.object(op.Parent)
+		if err != nil {
+			return err
+		}
+		obj := NewObject(op.Template.Name)
+		obj.id = op.Object
+		obj.tags = copyTags(op.Template.Tags)
+		for i := 0; i < len(op.Template.Components); i++ {
+			component, err := client.runtime.factory.newComponent(&op.Template.Components[i], client.runtime.services)
+			if err != nil {
+				return err
+			}
+			obj.attachComponent(component).Disabled = op.Template.Components[i].Disabled
+		}
+		obj.inactive = op.Template.Inactive
+		if err := parent.addObject(obj, false); err != nil {
+			return err
+		}
+		client.objects[op.Object] = obj
+		return nil
+	}
+
+	obj, err := client.object(op.Object)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case PatchRemoveObject:
+		if obj.parent != nil {
+			if err := obj.parent.RemoveObject(obj); err != nil {
+				return err
+			}
+		}
+		client.forget(obj)
+	case PatchMoveObject:
+		parent, err := client.object(op.Parent)
+		if err != nil {
+			return err
+		}
+		return parent.addObject(obj, false)
+	case PatchOrderObjects:
+		orderObjects(obj, op.Children)
+	case PatchRenameObject:
+		obj.WithLock(func() error {
+			obj.name = op.Name
+			return nil
+		})
+	case PatchSetActive:
+		obj.SetActive(!client.mirror.nodes[op.Object].template.Inactive)
+	case PatchSetTags:
+		obj.WithLock(func() error {
+			obj.tags = copyTags(op.Tags)
+			return nil
+		})
+	case PatchAddComponent:
+		component, err := client.runtime.factory.newComponent(&ComponentTemplate{Type: op.Type, Data: op.Value}, client.runtime.services)
+		if err != nil {
+			return err
+		}
+		obj.attachComponent(component)
+	case PatchOrderComponents:
+		orderComponents(obj, op.Types)
+	case PatchRemoveComponent:
+		info := findComponentInfo(obj, op.Type, op.Index)
+		if info == nil {
+			return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No component %s #%d on object '%s'", op.Type, op.Index, obj.name))
+		}
+		return obj.RemoveComponent(info.Component)
+	case PatchSetEnabled:
+		info := findComponentInfo(obj, op.Type, op.Index)
+		if info == nil {
+			return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No component %s #%d on object '%s'", op.Type, op.Index, obj.name))
+		}
+		return obj.SetEnabled(info.Component, !findPatchComponent(client.mirror.nodes[op.Object].template.Components, op.Type, op.Index).Disabled)
+	case PatchSetData, PatchSetField, PatchRemoveField:
+		info := findComponentInfo(obj, op.Type, op.Index)
+		if info == nil {
+			return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No component %s #%d on object '%s'", op.Type, op.Index, obj.name))
+		}
+		if info.Persist != nil {
+			data := findPatchComponent(client.mirror.nodes[op.Object].template.Components, op.Type, op.Index).Data
+			return info.Persist.Deserialize(data)
+		}
+	}
+	return nil
+}
+
+// forget removes an object and its replicated children from the id lookup
+func (client *ReplicationClient) forget(obj *Object) {
+	delete(client.objects, obj.id)
+	for i := 0; i < len(obj.children); i++ {
+		client.forget(obj.children[i])
+	}
+}
+
+// findComponentInfo returns the index'th component with the given type name on an object, or nil
+func findComponentInfo(obj *Object, componentType string, index int) *componentInfo {
+	for i := 0; i < len(obj.components); i++ {
+		if typeName(obj.components[i].Type) == componentType {
+			if index == 0 {
+				return obj.components[i]
+			}
+			index -= 1
+		}
+	}
+	return nil
+}
+
+// orderComponents puts the components of an object in the order of the given type names; the
+// components of each type keep their order, and any components that are not named follow in their
+// existing order.
+func orderComponents(obj *Object, types []string) {
+	obj.WithLock(func() error {
+		remaining := make(map[string][]*componentInfo)
+		for i := 0; i < len(obj.components); i++ {
+			name := typeName(obj.components[i].Type)
+			remaining[name] = append(remaining[name], obj.components[i])
+		}
+		components := make([]*componentInfo, 0, len(obj.components))
+		for i := 0; i < len(types); i++ {
+			if infos := remaining[types[i]]; len(infos) > 0 {
+				components = append(components, infos[0])
+				remaining[types[i]] = infos[1:]
+			}
+		}
+		for i := 0; i < len(obj.components); i++ {
+			name := typeName(obj.components[i].Type)
+			if infos := remaining[name]; len(infos) > 0 && infos[0] == obj.components[i] {
+				components = append(components, infos[0])
+				remaining[name] = infos[1:]
+			}
+		}
+		obj.components = components
+		return nil
+	})
+}
+
+// orderObjects puts the children of an object with the given ids first, in that order;
+// any other children follow in their existing order.
+func orderObjects(obj *Object, ids []string) {
+	obj.WithLock(func() error {
+		lookup := make(map[string]*Object, len(obj.children))
+		for i := 0; i < len(obj.children); i++ {
+			lookup[obj.children[i].id] = obj.children[i]
+		}
+		children := make([]*Object, 0, len(obj.children))
+		for i := 0; i < len(ids); i++ {
+			if child := lookup[ids[i]]; child != nil {
+				children = append(children, child)
+				delete(lookup, ids[i])
+			}
+		}
+		for i := 0; i < len(obj.children); i++ {
+			if lookup[obj.children[i].id] != nil {
+				children = append(children, obj.children[i])
+			}
+		}
+		obj.children = children
+		return nil
+	})
+}
@@ -0,0 +1,140 @@
+package component_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+// replicate runs a server tick and waits for the client to apply it
+func replicate(T *assert.T, server *component.ReplicationServer, client *component.ReplicationClient) {
+	done := make(chan error, 1)
+	go (func() {
+		done <- client.Receive()
+	})()
+	T.Assert(server.Tick() == nil)
+	T.Assert(<-done == nil)
+}
+
+func TestReplication(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		serverRuntime := component.NewRuntime(component.Config{})
+		serverRuntime.Factory().Register(&FakeComponent{})
+		serverRuntime.Factory().Register(&FakeStartComponent{})
+		server := component.NewReplicationServer(serverRuntime)
+
+		world := component.NewObject("World")
+		player := component.NewObject("Player")
+		player.AddComponent(&component.Replicated{})
+		player.AddComponent(&FakeComponent{Id: "Player", Count: 1})
+		sword := component.NewObject("Sword")
+		sword.AddComponent(&component.Replicated{})
+		sword.AddComponent(&FakeComponent{Id: "Sword", Count: 2})
+		player.AddObject(sword)
+		world.AddObject(player)
+		serverRuntime.Root().AddObject(world)
+
+		hidden := component.NewObject("Hidden")
+		hidden.AddComponent(&component.Replicated{})
+		serverRuntime.Root().AddObject(hidden)
+
+		clientRuntime := component.NewRuntime(component.Config{})
+		clientRuntime.Factory().Register(&FakeComponent{})
+		clientRuntime.Factory().Register(&FakeStartComponent{})
+
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+
+		peer := server.Connect(serverConn)
+		client := component.NewReplicationClient(clientRuntime, clientConn)
+		peer.Watch(world)
+
+		// Initial state; World is not replicated, so Player is a child of the client root
+		replicate(T, server, client)
+		T.Assert(client.Tick() == 1)
+		T.Assert(!clientRuntime.Root().HasObject("Hidden"))
+
+		var fake *FakeComponent
+		T.Assert(clientRuntime.Root().Find(&fake, "Player", "Sword") == nil)
+		T.Assert(fake.Id == "Sword")
+		T.Assert(fake.Count == 2)
+
+		remote, _ := clientRuntime.Root().FindObject("Player")
+		T.Assert(remote.ID() == player.ID())
+
+		// Persist data changes
+		var original *FakeComponent
+		T.Assert(sword.Find(&original) == nil)
+		original.Count = 10
+		replicate(T, server, client)
+		T.Assert(fake.Count == 10)
+
+		// Component order
+		var playerFake *FakeComponent
+		T.Assert(player.Find(&playerFake) == nil)
+		player.AddComponent(&FakeStartComponent{})
+		player.RemoveComponent(playerFake)
+		player.AddComponent(&FakeComponent{Id: "Player", Count: 3})
+		replicate(T, server, client)
+		template, err := clientRuntime.Factory().Serialize(remote)
+		T.Assert(err == nil)
+		T.Assert(len(template.Components) == 2)
+		T.Assert(template.Components[0].Type == "*ntoolkit/component_test.FakeStartComponent")
+		T.Assert(template.Components[1].Type == "*ntoolkit/component_test.FakeComponent")
+
+		// Removed objects
+		player.RemoveObject(sword)
+		replicate(T, server, client)
+		_, err = clientRuntime.Root().FindObject("Player", "Sword")
+		T.Assert(err != nil)
+
+		// Leaving the area of interest
+		peer.Unwatch(world)
+		replicate(T, server, client)
+		T.Assert(!clientRuntime.Root().HasObject("Player"))
+	})
+}
+
+func TestReplicationClientFailures(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		clientRuntime := component.NewRuntime(component.Config{NamePolicy: component.RejectDuplicateNames})
+		clientRuntime.Factory().Register(&FakeServiceComponent{})
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+		client := component.NewReplicationClient(clientRuntime, clientConn)
+		encoder := json.NewEncoder(serverConn)
+		send := func(operations ...component.PatchOperation) error {
+			done := make(chan error, 1)
+			go (func() {
+				done <- client.Receive()
+			})()
+			T.Assert(encoder.Encode(&component.ReplicationMessage{Tick: 1, Patch: component.Patch{Operations: operations}}) == nil)
+			return <-done
+		}
+
+		// An object that cannot be added locally is not kept by the client, so it can be sent again
+		service := &component.ObjectTemplate{Name: "Dice", Components: []component.ComponentTemplate{{Type: "*ntoolkit/component_test.FakeServiceComponent"}}}
+		err := send(component.PatchOperation{Op: component.PatchAddObject, Object: "1", Template: service})
+		T.Assert(errors.Is(err, component.ErrMissingService{}))
+		T.Assert(!clientRuntime.Root().HasObject("Dice"))
+		T.Assert(send(component.PatchOperation{Op: component.PatchAddObject, Object: "1", Template: &component.ObjectTemplate{Name: "Dice"}}) == nil)
+		T.Assert(clientRuntime.Root().HasObject("Dice"))
+
+		// Replicated objects keep their names, whatever the name policy
+		T.Assert(send(
+			component.PatchOperation{Op: component.PatchAddObject, Object: "2", Template: &component.ObjectTemplate{Name: "Dice"}},
+			component.PatchOperation{Op: component.PatchAddObject, Object: "3", Template: &component.ObjectTemplate{Name: "Cup"}},
+			component.PatchOperation{Op: component.PatchRenameObject, Object: "3", Name: "Dice"}) == nil)
+		T.Assert(clientRuntime.Root().ChildCount() == 3)
+		for i := 0; i < 3; i++ {
+			child, _ := clientRuntime.Root().Child(i)
+			T.Assert(child.Name() == "Dice")
+		}
+	})
+}
@@ -1,10 +1,12 @@
 package component
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"sync"
+	"time"
 
+	"ntoolkit/errors"
 	"ntoolkit/iter"
 	"ntoolkit/threadpool"
 )
@@ -13,28 +15,75 @@ import (
 type Config struct {
 	ThreadPoolSize int
 	Factory        *ObjectFactory
-	Logger         *log.Logger
+	Logger         *slog.Logger  // The logger for runtime events and components
+	LogHandler     slog.Handler  // The handler to log with, if there is no Logger
+	SlowFrame      time.Duration // Log a warning for any Update that takes longer than this, if set
+	LogSlowest     int           // Also log this many of the slowest components in a slow frame; turns on profiling
+	Profile        bool          // Time every component for the frame stats; see Runtime.Stats
+	StatsHistory   int           // The number of frames of stats to keep; no stats are kept without this, Profile or SlowFrame
+	Watchdog       time.Duration // Log any component Start or Update that runs for longer than this, if set
+	FaultHung      bool          // Stop starting or updating components the watchdog finds, and stop waiting for them
+	NamePolicy     NamePolicy    // How sibling objects with the same name are treated
+	TaskQueueSize  int           // The maximum number of scheduled tasks waiting to run
+	Index          IndexMode     // How components are indexed for queries
+	DebugAccess    bool          // Record and log undeclared access to components on other objects; slow
+	Services       *Services     // The services for components; also used by the factory if it has none
 }
 
 // Runtime is the basic operating unit of the mud.
 // A Runtime executes the main game loop on objects.
 type Runtime struct {
-	root       *Object                // The root object for this runtime.
-	workers    *threadpool.ThreadPool // The thread pool for updating objects
-	logger     *log.Logger            // The logger for this runtime, if any.
-	updateLock *sync.Mutex            // The thread safe lock for updates.
-	factory    *ObjectFactory         // The serialization factory
+	root          *Object                // The root object for this runtime.
+	workers       *threadpool.ThreadPool // The thread pool for updating objects
+	logger        *slog.Logger           // The logger for this runtime, if any.
+	updateLock    *sync.Mutex            // The thread safe lock for updates.
+	factory       *ObjectFactory         // The serialization factory
+	namePolicy    NamePolicy             // How sibling objects with the same name are treated
+	index         componentIndex         // The objects with each component type
+	indexLock     *sync.Mutex            // The lock for the component index
+	tasks         *taskQueue             // The tasks scheduled to run between updates
+	archetypes    *archetypeStore        // The archetype tables, if the runtime uses ArchetypeIndex
+	systems       *systemSet             // The systems that run each frame
+	systemsLock   *sync.Mutex            // The lock for changing the systems
+	accessTracker *accessTracker         // The record of undeclared access, if the runtime uses DebugAccess
+	services      *Services              // The services for components
+	slowFrame     time.Duration          // The duration of an Update that is logged as slow
+	logSlowest    int                    // The number of slowest components to log in a slow frame
+	profile       bool                   // Time every component
+	stats         *statsHistory          // The stats of the most recent frames, if any are kept
+	frames        uint64                 // The number of frames updated
+	watchdog      *watchdog              // The watchdog for hung components, if any
 }
 
 // New returns a new Runtime instance
 func NewRuntime(config Config) *Runtime {
 	validateConfig(&config)
 	runtime := &Runtime{
-		root:       NewObject(),
-		logger:     config.Logger,
-		updateLock: &sync.Mutex{},
-		workers:    threadpool.New(),
-		factory:    config.Factory}
+		root:        NewObject(),
+		logger:      config.Logger,
+		updateLock:  &sync.Mutex{},
+		workers:     threadpool.New(),
+		factory:     config.Factory,
+		namePolicy:  config.NamePolicy,
+		index:       make(componentIndex),
+		indexLock:   &sync.Mutex{},
+		tasks:       newTaskQueue(config.TaskQueueSize),
+		systems:     &systemSet{},
+		systemsLock: &sync.Mutex{},
+		services:    config.Services,
+		slowFrame:   config.SlowFrame,
+		logSlowest:  config.LogSlowest,
+		profile:     config.Profile || config.LogSlowest > 0,
+		stats:       newStatsHistory(config.StatsHistory)}
+	if config.Watchdog > 0 {
+		runtime.watchdog = newWatchdog(runtime, config.Watchdog, config.FaultHung)
+	}
+	if config.Index == ArchetypeIndex {
+		runtime.archetypes = newArchetypeStore()
+	}
+	if config.DebugAccess {
+		runtime.accessTracker = newAccessTracker()
+	}
 	runtime.root.runtime = runtime
 	runtime.workers.MaxThreads = config.ThreadPoolSize
 	return runtime
@@ -45,12 +94,27 @@ func validateConfig(config *Config) {
 	if config.ThreadPoolSize <= 0 {
 		config.ThreadPoolSize = 10
 	}
+	if config.Logger == nil && config.LogHandler != nil {
+		config.Logger = slog.New(config.LogHandler)
+	}
 	if config.Logger == nil {
-		config.Logger = log.New(os.Stdout, "runtime: ", log.Ldate|log.Ltime|log.Lshortfile)
+		config.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	if config.StatsHistory <= 0 && (config.Profile || config.LogSlowest > 0 || config.SlowFrame > 0) {
+		config.StatsHistory = 1
+	}
+	if config.TaskQueueSize <= 0 {
+		config.TaskQueueSize = 1000
 	}
 	if config.Factory == nil {
 		config.Factory = NewObjectFactory()
 	}
+	if config.Services == nil {
+		config.Services = NewServices()
+	}
+	if config.Factory.services == nil {
+		config.Factory.UseServices(config.Services)
+	}
 }
 
 // Return a reference to the root object for the runtime
@@ -58,6 +122,11 @@ func (runtime *Runtime) Root() *Object {
 	return runtime.root
 }
 
+// NamePolicy returns how the runtime treats sibling objects with the same name
+func (runtime *Runtime) NamePolicy() NamePolicy {
+	return runtime.namePolicy
+}
+
 // Factory returns the object factory for the runtime
 func (runtime *Runtime) Factory() *ObjectFactory {
 	return runtime.factory
@@ -79,11 +148,16 @@ func (runtime *Runtime) Extract(object *Object) (*ObjectTemplate, error) {
 
 // Insert converts the template into an object and attaches it as a child of the given parent.
 func (runtime *Runtime) Insert(template *ObjectTemplate, parent *Object) (*Object, error) {
+	if runtime.Closed() {
+		return nil, errors.Fail(ErrClosed{}, nil, "Unable to insert object; the runtime is closed")
+	}
 	rtn, err := runtime.factory.Deserialize(template)
 	if err != nil {
+		runtime.logger.Warn("Failed to deserialize object", "template", template.Name, "error", err)
 		return nil, err
 	}
 	if err := parent.AddObject(rtn); err != nil {
+		runtime.logger.Warn("Failed to insert object", "template", template.Name, "parent", parent.Path(), "error", err)
 		return nil, err
 	}
 	return rtn, nil
@@ -94,53 +168,143 @@ func (runtime *Runtime) Objects() iter.Iter {
 	return runtime.root.ObjectsInChildren()
 }
 
-// Schedules a task to execute between the next update loops.
-// Return immediately, but the task will only be executed after
-// the current Update() loop finishes.
-// This effectively blocks until the current loop ends, then runs; then
-// finally returns.
-func (runtime *Runtime) ScheduleTask(task func()) {
-	go (func() {
-		defer (func() {
-			r := recover()
-			if r != nil {
-				err, ok := r.(error)
-				if ok {
-					runtime.logger.Printf("Failed to execute scheduled task: %s", err.Error())
-				} else {
-					runtime.logger.Printf("Failed to execute scheduled task: %s", r)
-				}
+// Execute the update step of all components on all objects in worker threads.
+// Inactive and paused objects and their children are skipped without being visited.
+// Systems run once the objects are updated; see AddSystem. Scheduled tasks run before and after
+// the objects and systems are updated; see ScheduleTask. Stats are only collected for the frame if
+// the runtime keeps them; see Stats.
+// Returns an ErrClosed if the runtime has been closed.
+func (runtime *Runtime) Update(step float32) error {
+	runtime.updateLock.Lock()
+	defer runtime.updateLock.Unlock()
+	if runtime.Closed() {
+		return errors.Fail(ErrClosed{}, nil, "Unable to update; the runtime is closed")
+	}
+	runtime.frames += 1
+	if runtime.stats == nil {
+		runtime.runTasks(NextFrame)
+		runtime.updateObjects(step, nil)
+		runtime.runSystems(step)
+		runtime.runTasks(EndOfFrame)
+		return nil
+	}
+	frame := FrameStats{Frame: runtime.frames, Step: step, Started: time.Now()}
+	slowest := runtime.logSlowest
+	if slowest <= 0 {
+		slowest = 10
+	}
+	collector := newFrameCollector(runtime.profile, slowest)
+
+	phase := time.Now()
+	frame.Tasks += runtime.runTasks(NextFrame)
+	frame.Phases.Tasks = time.Since(phase)
+
+	phase = time.Now()
+	runtime.updateObjects(step, collector)
+	frame.Phases.Objects = time.Since(phase)
+
+	phase = time.Now()
+	runtime.runSystems(step)
+	frame.Phases.Systems = time.Since(phase)
+
+	phase = time.Now()
+	frame.Tasks += runtime.runTasks(EndOfFrame)
+	frame.Phases.EndOfFrameTasks = time.Since(phase)
+
+	frame.Duration = time.Since(frame.Started)
+	collector.finish(&frame)
+	runtime.stats.add(frame)
+	if runtime.slowFrame > 0 && frame.Duration > runtime.slowFrame {
+		runtime.logSlowFrame(&frame)
+	}
+	return nil
+}
+
+// minUpdateChunk is the smallest number of objects given to a worker in one update job, so small
+// trees are not spread over more jobs than they are worth
+const minUpdateChunk = 64
+
+// updateObjects updates the active objects and waits for the workers to finish.
+// Objects without any declared access are split into one chunk for each worker, and each chunk is
+// updated inline by a single job; different objects may be updated at the same time, but the
+// components of each object are updated in order by one worker. The objects with components that
+// declare access are updated afterwards, in batches that have no conflicting access; see Access.
+// The watchdog, if any, checks the components for hangs until the objects are updated.
+func (runtime *Runtime) updateObjects(step float32, collector *frameCollector) {
+	if runtime.root.inactive || runtime.root.timeScale == 0 {
+		return
+	}
+	if runtime.watchdog != nil {
+		stop := runtime.watchdog.start()
+		defer stop()
+	}
+	queue := []*Object{runtime.root}
+	scales := []float32{runtime.root.timeScale}
+	for i := 0; i < len(queue); i++ {
+		children := queue[i].children
+		for j := 0; j < len(children); j++ {
+			scale := scales[i] * children[j].timeScale
+			if !children[j].inactive && scale != 0 {
+				queue = append(queue, children[j])
+				scales = append(scales, scale)
 			}
-		})()
-		runtime.updateLock.Lock()
-		task()
-		defer runtime.updateLock.Unlock()
-	})()
+		}
+	}
+
+	free := make([]*Object, 0, len(queue))
+	freeScales := make([]float32, 0, len(queue))
+	declared := make([]*Object, 0)
+	declaredScales := make([]float32, 0)
+	declaredAccess := make([]ComponentAccess, 0)
+	for i := 0; i < len(queue); i++ {
+		if access, ok := queue[i].objectAccess(); ok {
+			declared = append(declared, queue[i])
+			declaredScales = append(declaredScales, scales[i])
+			declaredAccess = append(declaredAccess, access)
+		} else {
+			free = append(free, queue[i])
+			freeScales = append(freeScales, scales[i])
+		}
+	}
+	runtime.updateParallel(step, freeScales, free, collector)
+	if len(declared) > 0 {
+		batches, batchScales := accessBatches(declared, declaredScales, declaredAccess)
+		for i := 0; i < len(batches); i++ {
+			runtime.updateParallel(step, batchScales[i], batches[i], collector)
+		}
+	}
 }
 
-// Execute the update step of all components on all objects in worker threads
-func (runtime *Runtime) Update(step float32) {
-	runtime.updateLock.Lock()
-	defer runtime.updateLock.Unlock()
-	runtime.updateObject(step, runtime.root)
-	objects := runtime.Objects()
-	if objects != nil {
-		var val interface{}
-		var err error
-		for val, err = objects.Next(); err == nil; val, err = objects.Next() {
-			obj := val.(*Object)
-			runtime.updateObject(step, obj)
+// updateParallel updates a set of objects in chunks on the workers, and waits for them to finish
+func (runtime *Runtime) updateParallel(step float32, scales []float32, objects []*Object, collector *frameCollector) {
+	chunk := (len(objects) + runtime.workers.MaxThreads - 1) / runtime.workers.MaxThreads
+	if chunk < minUpdateChunk {
+		chunk = minUpdateChunk
+	}
+	for offset := 0; offset < len(objects); offset += chunk {
+		end := offset + chunk
+		if end > len(objects) {
+			end = len(objects)
 		}
-		runtime.workers.Wait()
+		runtime.updateChunk(step, scales[offset:end], objects[offset:end], collector)
 	}
+	runtime.workers.Wait()
 }
 
-// Execute a single object update
-func (runtime *Runtime) updateObject(step float32, obj *Object) {
+// updateChunk updates a set of objects in a single job
+func (runtime *Runtime) updateChunk(step float32, scales []float32, objects []*Object, collector *frameCollector) {
 	runtime.workers.Run(func() {
-		if obj.runtime == nil {
-			obj.runtime = runtime
+		if runtime.watchdog != nil {
+			runtime.watchdog.updateChunk(step, scales, objects, collector)
+			return
+		}
+		stats := collector.worker()
+		for i := 0; i < len(objects); i++ {
+			if objects[i].runtime == nil {
+				objects[i].runtime = runtime
+			}
+			objects[i].update(step, scales[i], runtime, stats)
 		}
-		obj.Update(step, runtime)
+		collector.merge(stats)
 	})
 }
@@ -0,0 +1,51 @@
+package component
+
+import (
+	"context"
+
+	"ntoolkit/errors"
+)
+
+// Closed checks if Close has been called on the runtime.
+func (runtime *Runtime) Closed() bool {
+	return runtime.tasks.isClosed()
+}
+
+// Close shuts the runtime down. It stops accepting new tasks, waits for any Update in progress, runs
+// the tasks that are already queued, cancels all timers and coroutines, invokes OnDestroy on every
+// component in the tree, children first, and waits for the workers to finish. Afterwards Update and
+// Insert fail with ErrClosed.
+//
+// If any OnDestroy fails the rest are still invoked, and an ErrDestroyFailed with all of the
+// failures is returned. If ctx is done first, Close returns its error immediately and the shutdown
+// carries on in the background. Closing a runtime more than once returns an ErrClosed.
+func (runtime *Runtime) Close(ctx context.Context) error {
+	if !runtime.tasks.close() {
+		return errors.Fail(ErrClosed{}, nil, "Unable to close; the runtime is already closed")
+	}
+	done := make(chan error, 1)
+	go (func() {
+		done <- runtime.shutdown()
+	})()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown drains the task queue and destroys the tree once no Update is running
+func (runtime *Runtime) shutdown() error {
+	runtime.updateLock.Lock()
+	defer runtime.updateLock.Unlock()
+	runtime.runTasks(NextFrame)
+	runtime.runTasks(EndOfFrame)
+	failures := runtime.root.destroy(runtime, nil)
+	runtime.workers.Wait()
+	err := destroyFailed(failures, "Failed to close runtime")
+	if err != nil {
+		runtime.logger.Error("Failed to close runtime", "error", err, "failures", len(failures))
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package component_test
+
+import (
+	"context"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestClose(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		parent := component.NewObject("Parent")
+		child := component.NewObject("Child")
+		parentFake := &FakeDestroyComponent{}
+		childFake := &FakeDestroyComponent{}
+		parent.AddComponent(parentFake)
+		child.AddComponent(childFake)
+		parent.AddObject(child)
+		runtime.Root().AddObject(parent)
+
+		ran := false
+		task := runtime.ScheduleTask(func() error {
+			ran = true
+			return nil
+		})
+
+		T.Assert(runtime.Close(context.Background()) == nil)
+		T.Assert(runtime.Closed())
+		T.Assert(ran)
+		T.Assert(<-task == nil)
+		T.Assert(parentFake.Destroys == 1)
+		T.Assert(childFake.Destroys == 1)
+
+		T.Assert(errors.Is(runtime.Update(1), component.ErrClosed{}))
+		_, err := runtime.Insert(&component.ObjectTemplate{Name: "Late"}, runtime.Root())
+		T.Assert(errors.Is(err, component.ErrClosed{}))
+		T.Assert(errors.Is(<-runtime.ScheduleTask(func() error { return nil }), component.ErrClosed{}))
+		T.Assert(errors.Is(runtime.Close(context.Background()), component.ErrClosed{}))
+		T.Assert(parentFake.Destroys == 1)
+	})
+}
+
+func TestCloseFailures(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		for _, name := range []string{"A", "B", "C"} {
+			obj := component.NewObject(name)
+			obj.AddComponent(&FakeDestroyComponent{Fail: name != "B"})
+			runtime.Root().AddObject(obj)
+		}
+		err := runtime.Close(context.Background())
+		T.Assert(errors.Is(err, component.ErrDestroyFailed{}))
+		T.Assert(err.Error() != "")
+	})
+}
+
+func TestCloseTimeout(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		release := make(chan bool)
+		runtime.ScheduleTask(func() error {
+			<-release
+			return nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		T.Assert(runtime.Close(ctx) == context.Canceled)
+		T.Assert(runtime.Closed())
+		close(release)
+	})
+}
+
+func TestDestroy(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		parent := component.NewObject("Parent")
+		child := component.NewObject("Child")
+		fake := &FakeDestroyComponent{Fail: true}
+		child.AddComponent(fake)
+		parent.AddObject(child)
+		runtime.Root().AddObject(parent)
+
+		err := parent.Destroy()
+		T.Assert(errors.Is(err, component.ErrDestroyFailed{}))
+		T.Assert(fake.Destroys == 1)
+		T.Assert(parent.Parent() == nil)
+	})
+}
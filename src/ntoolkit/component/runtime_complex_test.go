@@ -2,15 +2,14 @@ package component_test
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"reflect"
 	"testing"
 
 	"ntoolkit/assert"
 	"ntoolkit/component"
 	"strings"
-	"io/ioutil"
+	"io"
 	"ntoolkit/iter"
 )
 
@@ -35,7 +34,7 @@ func (c *AddRemoveChild) Attach(parent *component.Object) {
 }
 
 func (c *AddRemoveChild) Update(context *component.Context) {
-	context.Logger.Printf("Update: %s", c.parent.Name())
+	context.Logger.Info("Update", "name", c.parent.Name())
 	c.elapsed += context.DeltaTime
 	if c.elapsed > 1.0 {
 		c.count += 1
@@ -67,19 +66,18 @@ func (c *DumpState) Type() reflect.Type {
 
 func (c *DumpState) Update(context *component.Context) {
 	c.elapsed += context.DeltaTime
-	context.Logger.Printf("DumpState: %f", c.elapsed)
+	context.Logger.Info("DumpState", "elapsed", c.elapsed)
 	if c.elapsed >= 0.5 {
 		c.elapsed = 0.0
 		root := context.Object.Root()
 		structure := root.Debug()
-		context.Logger.Printf("Tree: %s", structure)
+		context.Logger.Info("Tree", "structure", structure)
 	}
 }
 
 func TestComplexSerialization(T *testing.T) {
 	assert.Test(T, func(T *assert.T) {
-		logger := log.New(os.Stdout, "Runtime: ", log.Ldate|log.Ltime|log.Lshortfile)
-		logger.SetOutput(ioutil.Discard) // No output thanks
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil)) // No output thanks
 
 		runtime := component.NewRuntime(component.Config{
 			ThreadPoolSize: 10,
@@ -139,8 +137,7 @@ func TestComplexSerialization(T *testing.T) {
 
 func TestComplexRuntime(T *testing.T) {
 	assert.Test(T, func(T *assert.T) {
-		logger := log.New(os.Stdout, "Runtime: ", log.Ldate|log.Ltime|log.Lshortfile)
-		logger.SetOutput(ioutil.Discard) // No output thanks
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil)) // No output thanks
 
 		runtime := component.NewRuntime(component.Config{
 			ThreadPoolSize: 10,
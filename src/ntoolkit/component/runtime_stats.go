@@ -0,0 +1,277 @@
+package component
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FrameStats describes a single Update of a runtime.
+type FrameStats struct {
+	Frame      uint64        // The number of the frame, counting from 1
+	Step       float32       // The step the frame was updated with
+	Started    time.Time     // When the frame started
+	Duration   time.Duration // The wall time of the whole frame
+	Phases     PhaseStats    // The wall time of each part of the frame
+	Objects    int           // The number of objects updated
+	Components int           // The number of components started or updated
+	Tasks      int           // The number of scheduled tasks run
+
+	// The time spent in each component type, by type name; only collected when profiling
+	ComponentTypes map[string]ComponentTypeStats
+
+	// The slowest components of the frame, slowest first; only collected when profiling
+	Slowest []ComponentTiming
+}
+
+// PhaseStats is the wall time spent in each part of a frame.
+type PhaseStats struct {
+	Tasks           time.Duration // Running the next frame tasks
+	Objects         time.Duration // Updating objects
+	Systems         time.Duration // Running systems
+	EndOfFrameTasks time.Duration // Running the end of frame tasks
+}
+
+// ComponentTypeStats is the time spent in the components of one type in a frame.
+type ComponentTypeStats struct {
+	Count    int           // The number of components of the type started or updated
+	Duration time.Duration // The total time spent in them, across all workers
+}
+
+// ComponentTiming is the time spent in a single component in a frame.
+type ComponentTiming struct {
+	Object   *Object
+	Path     string // The path of the object; see Object.Path
+	Type     string // The type name of the component
+	Duration time.Duration
+}
+
+// updateStats collects the stats of the objects updated by one worker job
+type updateStats struct {
+	objects    int
+	components int
+	profile    bool                                 // Time each component
+	slowest    int                                  // The number of slowest components to keep
+	types      map[reflect.Type]*ComponentTypeStats // The time spent in each component type
+	timings    []ComponentTiming                    // The slowest components, slowest first
+	watch      *watch                               // The watchdog entry for the job, if the runtime has a watchdog
+}
+
+func newUpdateStats(profile bool, slowest int) *updateStats {
+	rtn := &updateStats{profile: profile, slowest: slowest}
+	if profile {
+		rtn.types = make(map[reflect.Type]*ComponentTypeStats)
+	}
+	return rtn
+}
+
+// object records that an object is being updated
+func (stats *updateStats) object() {
+	if stats.watch == nil {
+		stats.objects += 1
+		return
+	}
+	stats.watch.record(func() {
+		stats.objects += 1
+	})
+}
+
+// update starts or updates a component and records it. With a watchdog, nothing is recorded once the
+// job has been abandoned, as its stats have already been merged.
+func (stats *updateStats) update(object *Object, info *componentInfo, context *Context) {
+	if stats.watch != nil && !stats.watch.enter(object, info) {
+		return
+	}
+	var started time.Time
+	if stats.profile {
+		started = time.Now()
+	}
+	updated := info.updateComponent(context)
+	record := func() {
+		if updated {
+			stats.components += 1
+			if stats.profile {
+				stats.component(object, info, time.Since(started))
+			}
+		}
+	}
+	if stats.watch == nil {
+		record()
+	} else {
+		stats.watch.leave(record)
+	}
+}
+
+// component records the time spent in a component
+func (stats *updateStats) component(object *Object, info *componentInfo, duration time.Duration) {
+	typeStats := stats.types[info.Type]
+	if typeStats == nil {
+		typeStats = &ComponentTypeStats{}
+		stats.types[info.Type] = typeStats
+	}
+	typeStats.Count += 1
+	typeStats.Duration += duration
+	stats.timing(ComponentTiming{Object: object, Type: typeName(info.Type), Duration: duration})
+}
+
+// timing keeps a component timing if it is one of the slowest
+func (stats *updateStats) timing(timing ComponentTiming) {
+	if stats.slowest <= 0 {
+		return
+	}
+	if len(stats.timings) == stats.slowest && stats.timings[len(stats.timings)-1].Duration >= timing.Duration {
+		return
+	}
+	index := sort.Search(len(stats.timings), func(i int) bool { return stats.timings[i].Duration < timing.Duration })
+	stats.timings = append(stats.timings, ComponentTiming{})
+	copy(stats.timings[index+1:], stats.timings[index:])
+	stats.timings[index] = timing
+	if len(stats.timings) > stats.slowest {
+		stats.timings = stats.timings[:stats.slowest]
+	}
+}
+
+// frameCollector merges the stats of every worker job in a frame
+type frameCollector struct {
+	stats   *updateStats
+	profile bool
+	slowest int
+	lock    *sync.Mutex
+}
+
+func newFrameCollector(profile bool, slowest int) *frameCollector {
+	return &frameCollector{
+		stats:   newUpdateStats(profile, slowest),
+		profile: profile,
+		slowest: slowest,
+		lock:    &sync.Mutex{}}
+}
+
+// worker returns new stats for a worker job to collect into, or nil if nothing is collected
+func (collector *frameCollector) worker() *updateStats {
+	if collector == nil {
+		return nil
+	}
+	return newUpdateStats(collector.profile, collector.slowest)
+}
+
+// merge adds the stats of a worker job
+func (collector *frameCollector) merge(stats *updateStats) {
+	if collector == nil {
+		return
+	}
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+	total := collector.stats
+	total.objects += stats.objects
+	total.components += stats.components
+	for T, typeStats := range stats.types {
+		if existing := total.types[T]; existing != nil {
+			existing.Count += typeStats.Count
+			existing.Duration += typeStats.Duration
+		} else {
+			total.types[T] = typeStats
+		}
+	}
+	for i := 0; i < len(stats.timings); i++ {
+		total.timing(stats.timings[i])
+	}
+}
+
+// finish fills in the object and component stats of a frame
+func (collector *frameCollector) finish(frame *FrameStats) {
+	stats := collector.stats
+	frame.Objects = stats.objects
+	frame.Components = stats.components
+	if !collector.profile {
+		return
+	}
+	frame.ComponentTypes = make(map[string]ComponentTypeStats, len(stats.types))
+	for T, typeStats := range stats.types {
+		frame.ComponentTypes[typeName(T)] = *typeStats
+	}
+	frame.Slowest = stats.timings
+	for i := 0; i < len(frame.Slowest); i++ {
+		frame.Slowest[i].Path = frame.Slowest[i].Object.Path()
+	}
+}
+
+// statsHistory holds the stats of the most recent frames of a runtime
+type statsHistory struct {
+	frames []FrameStats // A ring of the most recent frames
+	next   int          // The index the next frame is written to
+	count  uint64       // The number of frames recorded
+	lock   *sync.Mutex
+}
+
+func newStatsHistory(size int) *statsHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &statsHistory{frames: make([]FrameStats, size), lock: &sync.Mutex{}}
+}
+
+// add records the stats of a frame
+func (history *statsHistory) add(frame FrameStats) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	history.frames[history.next] = frame
+	history.next = (history.next + 1) % len(history.frames)
+	history.count += 1
+}
+
+// Stats returns the stats of the last frame, or an empty FrameStats if there has not been one or
+// the runtime keeps no stats; see Config.StatsHistory.
+func (runtime *Runtime) Stats() FrameStats {
+	history := runtime.stats
+	if history == nil {
+		return FrameStats{}
+	}
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	if history.count == 0 {
+		return FrameStats{}
+	}
+	return history.frames[(history.next+len(history.frames)-1)%len(history.frames)]
+}
+
+// StatsHistory returns the stats of the most recent frames, oldest first; up to Config.StatsHistory
+// frames are kept.
+func (runtime *Runtime) StatsHistory() []FrameStats {
+	history := runtime.stats
+	if history == nil {
+		return nil
+	}
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	count := len(history.frames)
+	if history.count < uint64(count) {
+		count = int(history.count)
+	}
+	rtn := make([]FrameStats, 0, count)
+	for i := count; i > 0; i-- {
+		rtn = append(rtn, history.frames[(history.next+len(history.frames)-i)%len(history.frames)])
+	}
+	return rtn
+}
+
+// logSlowFrame logs a frame that took longer than the slow frame limit, with its slowest components
+func (runtime *Runtime) logSlowFrame(frame *FrameStats) {
+	runtime.logger.Warn("Slow frame",
+		"frame", frame.Frame,
+		"duration", frame.Duration,
+		"limit", runtime.slowFrame,
+		"step", frame.Step,
+		"objects", frame.Objects,
+		"components", frame.Components)
+	for i := 0; i < len(frame.Slowest) && i < runtime.logSlowest; i++ {
+		runtime.logger.Warn("Slow component",
+			"frame", frame.Frame,
+			"rank", i+1,
+			"duration", frame.Slowest[i].Duration,
+			"object_id", frame.Slowest[i].Object.id,
+			"object_path", frame.Slowest[i].Path,
+			"component", frame.Slowest[i].Type)
+	}
+}
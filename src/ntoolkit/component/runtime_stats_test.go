@@ -0,0 +1,135 @@
+package component_test
+
+import (
+	"bytes"
+	"log/slog"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"testing"
+	"time"
+)
+
+func TestFrameStats(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{Profile: true})
+		T.Assert(runtime.Stats().Frame == 0)
+		for i := 0; i < 10; i++ {
+			obj := component.NewObject()
+			obj.AddComponent(&FakeComponent{})
+			obj.AddComponent(&FakeSlowComponent{Delay: time.Millisecond})
+			runtime.Root().AddObject(obj)
+		}
+		runtime.ScheduleTask(func() error { return nil }, component.TaskOptions{NoWait: true})
+		runtime.ScheduleTask(func() error { return nil }, component.TaskOptions{Timing: component.EndOfFrame, NoWait: true})
+
+		runtime.Update(1)
+		stats := runtime.Stats()
+		T.Assert(stats.Frame == 1)
+		T.Assert(stats.Step == 1)
+		T.Assert(stats.Objects == 11)
+		T.Assert(stats.Components == 20)
+		T.Assert(stats.Tasks == 2)
+		T.Assert(stats.Phases.Objects >= 10*time.Millisecond)
+		T.Assert(stats.Duration >= stats.Phases.Objects)
+
+		slow := stats.ComponentTypes["*ntoolkit/component_test.FakeSlowComponent"]
+		T.Assert(slow.Count == 10)
+		T.Assert(slow.Duration >= 10*time.Millisecond)
+		T.Assert(stats.ComponentTypes["*ntoolkit/component_test.FakeComponent"].Count == 10)
+		T.Assert(len(stats.Slowest) == 10)
+		for i := 0; i < len(stats.Slowest); i++ {
+			T.Assert(stats.Slowest[i].Type == "*ntoolkit/component_test.FakeSlowComponent")
+			T.Assert(stats.Slowest[i].Path == "/"+stats.Slowest[i].Object.Name())
+			if i > 0 {
+				T.Assert(stats.Slowest[i-1].Duration >= stats.Slowest[i].Duration)
+			}
+		}
+	})
+}
+
+func TestFrameStatsWithoutProfile(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{StatsHistory: 1})
+		obj := component.NewObject()
+		obj.AddComponent(&FakeComponent{})
+		runtime.Root().AddObject(obj)
+
+		runtime.Update(1)
+		stats := runtime.Stats()
+		T.Assert(stats.Objects == 2)
+		T.Assert(stats.Components == 1)
+		T.Assert(stats.ComponentTypes == nil)
+		T.Assert(len(stats.Slowest) == 0)
+	})
+}
+
+func TestNoStatsByDefault(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject()
+		fake := &FakeComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+
+		T.Assert(runtime.Update(1) == nil)
+		T.Assert(runtime.Update(1) == nil)
+		T.Assert(fake.Count > 0)
+		T.Assert(runtime.Stats().Frame == 0)
+		T.Assert(runtime.StatsHistory() == nil)
+
+		// A slow frame limit keeps the last frame
+		slow := component.NewRuntime(component.Config{SlowFrame: time.Hour})
+		slow.Update(1)
+		T.Assert(slow.Stats().Frame == 1)
+		T.Assert(len(slow.StatsHistory()) == 1)
+	})
+}
+
+func TestStatsHistory(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{StatsHistory: 3})
+		T.Assert(len(runtime.StatsHistory()) == 0)
+
+		runtime.Update(1)
+		runtime.Update(2)
+		history := runtime.StatsHistory()
+		T.Assert(len(history) == 2)
+		T.Assert(history[0].Frame == 1)
+		T.Assert(history[1].Frame == 2)
+
+		runtime.Update(3)
+		runtime.Update(4)
+		runtime.Update(5)
+		history = runtime.StatsHistory()
+		T.Assert(len(history) == 3)
+		T.Assert(history[0].Frame == 3 && history[0].Step == 3)
+		T.Assert(history[2].Frame == 5 && history[2].Step == 5)
+		T.Assert(runtime.Stats().Frame == 5)
+	})
+}
+
+func TestLogSlowestComponents(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		output := &bytes.Buffer{}
+		runtime := component.NewRuntime(component.Config{
+			LogHandler: slog.NewJSONHandler(output, nil),
+			SlowFrame:  time.Millisecond,
+			LogSlowest: 2})
+		for i := 0; i < 5; i++ {
+			obj := component.NewObject()
+			obj.AddComponent(&FakeSlowComponent{Delay: time.Duration(i+1) * time.Millisecond})
+			runtime.Root().AddObject(obj)
+		}
+		runtime.Update(1)
+
+		records := logRecords(output)
+		T.Assert(len(records) == 3)
+		T.Assert(records[0]["msg"] == "Slow frame")
+		T.Assert(records[1]["msg"] == "Slow component")
+		T.Assert(records[1]["rank"] == 1.0)
+		T.Assert(records[1]["component"] == "*ntoolkit/component_test.FakeSlowComponent")
+		T.Assert(records[2]["rank"] == 2.0)
+		T.Assert(records[1]["duration"].(float64) >= records[2]["duration"].(float64))
+		T.Assert(records[2]["duration"].(float64) >= float64(4*time.Millisecond))
+	})
+}
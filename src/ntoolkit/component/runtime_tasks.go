@@ -0,0 +1,156 @@
+package component
+
+import (
+	"fmt"
+	"sync"
+
+	"ntoolkit/errors"
+)
+
+// TaskTiming controls when a scheduled task runs relative to the frame loop.
+type TaskTiming int
+
+const (
+	// NextFrame tasks run at the start of the next Update, before any object is updated.
+	NextFrame TaskTiming = iota
+
+	// EndOfFrame tasks run at the end of the current Update, after every object has been updated; if
+	// no Update is running, at the end of the next one.
+	EndOfFrame
+)
+
+// TaskOptions configures a scheduled task.
+type TaskOptions struct {
+	Timing TaskTiming // When the task runs
+	NoWait bool       // Fail with ErrQueueFull rather than waiting if the task queue is full
+}
+
+// scheduledTask is a task waiting in the runtime task queue
+type scheduledTask struct {
+	action func() error
+	result chan error
+}
+
+// taskQueue holds the pending tasks of a runtime, in the order they were scheduled
+type taskQueue struct {
+	pending [2][]*scheduledTask // The pending tasks for each TaskTiming
+	slots   chan struct{}       // A slot for each task that may be queued at once
+	closed  chan struct{}       // Closed when the queue stops accepting tasks
+	lock    *sync.Mutex
+}
+
+func newTaskQueue(size int) *taskQueue {
+	return &taskQueue{
+		slots:  make(chan struct{}, size),
+		closed: make(chan struct{}),
+		lock:   &sync.Mutex{}}
+}
+
+// ScheduleTask queues a task to run between object updates, and returns a channel that receives the
+// result of the task once it has run. Tasks with the same timing run in the order they were
+// scheduled, one at a time, while no objects are being updated; see TaskTiming. A task that panics
+// fails with the panic as the error. Once the runtime is closed, tasks fail with ErrClosed, and an
+// unknown timing fails with ErrBadValue.
+//
+// The queue holds at most Config.TaskQueueSize tasks; when it is full ScheduleTask waits for a slot,
+// unless NoWait is set. Tasks only run during Update or RunTasks, so scheduling into a full queue
+// from inside an update without NoWait will block forever.
+func (runtime *Runtime) ScheduleTask(task func() error, options ...TaskOptions) <-chan error {
+	option := TaskOptions{}
+	if len(options) > 0 {
+		option = options[0]
+	}
+	scheduled := &scheduledTask{action: task, result: make(chan error, 1)}
+	if option.Timing != NextFrame && option.Timing != EndOfFrame {
+		scheduled.result <- errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Unable to schedule task; invalid timing %d", option.Timing))
+		return scheduled.result
+	}
+	queue := runtime.tasks
+	if option.NoWait {
+		select {
+		case queue.slots <- struct{}{}:
+		default:
+			scheduled.result <- errors.Fail(ErrQueueFull{}, nil, fmt.Sprintf("Unable to schedule task; the queue is full with %d tasks", cap(queue.slots)))
+			return scheduled.result
+		}
+	} else {
+		select {
+		case queue.slots <- struct{}{}:
+		case <-queue.closed:
+		}
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.isClosed() {
+		scheduled.result <- errors.Fail(ErrClosed{}, nil, "Unable to schedule task; the runtime is closed")
+		return scheduled.result
+	}
+	queue.pending[option.Timing] = append(queue.pending[option.Timing], scheduled)
+	return scheduled.result
+}
+
+// isClosed checks if the queue has stopped accepting tasks
+func (queue *taskQueue) isClosed() bool {
+	select {
+	case <-queue.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the queue accepting tasks; tasks already queued can still be run.
+// Returns false if the queue was already closed.
+func (queue *taskQueue) close() bool {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.isClosed() {
+		return false
+	}
+	close(queue.closed)
+	return true
+}
+
+// RunTasks runs every queued task now, next frame tasks first, for runtimes that are not updated in
+// a loop. It waits for any Update in progress to finish.
+func (runtime *Runtime) RunTasks() {
+	runtime.updateLock.Lock()
+	defer runtime.updateLock.Unlock()
+	runtime.runTasks(NextFrame)
+	runtime.runTasks(EndOfFrame)
+}
+
+// runTasks runs the tasks queued with the given timing, and returns how many were run. Tasks
+// scheduled by these tasks wait for the next time the queue is run.
+func (runtime *Runtime) runTasks(timing TaskTiming) int {
+	queue := runtime.tasks
+	queue.lock.Lock()
+	pending := queue.pending[timing]
+	queue.pending[timing] = nil
+	queue.lock.Unlock()
+	for i := 0; i < len(pending); i++ {
+		<-queue.slots
+		err := pending[i].run()
+		if errors.Is(err, ErrTaskFailed{}) {
+			runtime.logger.Error("Scheduled task panicked", "error", err)
+		} else if err != nil {
+			runtime.logger.Warn("Scheduled task failed", "error", err)
+		}
+		pending[i].result <- err
+	}
+	return len(pending)
+}
+
+// run executes the task, recovering from any panic
+func (task *scheduledTask) run() (err error) {
+	defer (func() {
+		if r := recover(); r != nil {
+			inner, ok := r.(error)
+			if !ok {
+				inner = fmt.Errorf("%v", r)
+			}
+			err = errors.Fail(ErrTaskFailed{}, inner, "Failed to execute scheduled task")
+		}
+	})()
+	return task.action()
+}
@@ -0,0 +1,101 @@
+package component_test
+
+import (
+	"fmt"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestScheduleTaskOrder(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		obj := component.NewObject("Object")
+		fake := &FakeStartComponent{}
+		obj.AddComponent(fake)
+		runtime.Root().AddObject(obj)
+
+		order := make([]string, 0)
+		record := func(name string) func() error {
+			return func() error {
+				order = append(order, fmt.Sprintf("%s:%d", name, fake.Starts+fake.Updates))
+				return nil
+			}
+		}
+		results := []<-chan error{
+			runtime.ScheduleTask(record("end"), component.TaskOptions{Timing: component.EndOfFrame}),
+			runtime.ScheduleTask(record("a")),
+			runtime.ScheduleTask(record("b")),
+		}
+		T.Assert(len(order) == 0)
+
+		runtime.Update(1)
+		for _, result := range results {
+			T.Assert(<-result == nil)
+		}
+		T.Assert(len(order) == 3)
+		T.Assert(order[0] == "a:0")
+		T.Assert(order[1] == "b:0")
+		T.Assert(order[2] == "end:1")
+	})
+}
+
+func TestScheduleTaskErrors(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		failed := runtime.ScheduleTask(func() error {
+			return errors.Fail(component.ErrBadValue{}, nil, "Bad value")
+		})
+		panicked := runtime.ScheduleTask(func() error {
+			panic("Oh no")
+		})
+		runtime.RunTasks()
+		T.Assert(errors.Is(<-failed, component.ErrBadValue{}))
+		T.Assert(errors.Is(<-panicked, component.ErrTaskFailed{}))
+	})
+}
+
+func TestScheduleTaskInvalidTiming(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{TaskQueueSize: 1})
+		ran := false
+		result := runtime.ScheduleTask(func() error {
+			ran = true
+			return nil
+		}, component.TaskOptions{Timing: component.TaskTiming(7), NoWait: true})
+		T.Assert(errors.Is(<-result, component.ErrBadValue{}))
+
+		// The queue has room for a valid task
+		valid := runtime.ScheduleTask(func() error { return nil }, component.TaskOptions{NoWait: true})
+		runtime.RunTasks()
+		T.Assert(<-valid == nil)
+		T.Assert(!ran)
+	})
+}
+
+func TestScheduleTaskQueueFull(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{TaskQueueSize: 2})
+		count := 0
+		task := func() error {
+			count += 1
+			return nil
+		}
+		runtime.ScheduleTask(task)
+		runtime.ScheduleTask(task)
+		full := runtime.ScheduleTask(task, component.TaskOptions{NoWait: true})
+		T.Assert(errors.Is(<-full, component.ErrQueueFull{}))
+
+		// A blocked task is queued as soon as there is room
+		blocked := make(chan (<-chan error))
+		go (func() {
+			blocked <- runtime.ScheduleTask(task)
+		})()
+		runtime.RunTasks()
+		result := <-blocked
+		runtime.RunTasks()
+		T.Assert(<-result == nil)
+		T.Assert(count == 3)
+	})
+}
@@ -0,0 +1,56 @@
+package component_test
+
+import (
+	"fmt"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"testing"
+)
+
+// buildTree adds groups*size objects to the runtime, each with a FakeStartComponent
+func buildTree(runtime *component.Runtime, groups int, size int) []*FakeStartComponent {
+	fakes := make([]*FakeStartComponent, 0, groups*size)
+	for i := 0; i < groups; i++ {
+		group := component.NewObject(fmt.Sprintf("Group %d", i))
+		for j := 0; j < size; j++ {
+			obj := component.NewObject(fmt.Sprintf("Object %d", j))
+			fake := &FakeStartComponent{}
+			obj.AddComponent(fake)
+			group.AddObject(obj)
+			fakes = append(fakes, fake)
+		}
+		runtime.Root().AddObject(group)
+	}
+	return fakes
+}
+
+func TestUpdateLargeTree(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{ThreadPoolSize: 4})
+		fakes := buildTree(runtime, 10, 1000)
+		runtime.Update(1)
+		runtime.Update(1)
+		for _, fake := range fakes {
+			T.Assert(fake.Starts == 1)
+			T.Assert(fake.Updates == 1)
+		}
+	})
+}
+
+func benchmarkUpdate(b *testing.B, groups int, size int) {
+	runtime := component.NewRuntime(component.Config{})
+	buildTree(runtime, groups, size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.Update(1)
+	}
+	b.ReportMetric(float64(groups*size*b.N)/b.Elapsed().Seconds(), "objects/s")
+}
+
+func BenchmarkUpdateWide100k(b *testing.B) {
+	benchmarkUpdate(b, 1, 100000)
+}
+
+func BenchmarkUpdateGroups100k(b *testing.B) {
+	benchmarkUpdate(b, 1000, 100)
+}
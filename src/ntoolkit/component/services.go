@@ -0,0 +1,178 @@
+package component
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"ntoolkit/errors"
+)
+
+// Services is a registry of shared services, such as database handles, random number generators
+// or configuration, that components look up by type rather than through package globals.
+//
+// Components can also have services injected into exported fields tagged with `service:""` when they
+// are attached to an object in a runtime, or deserialized by a factory with services. The field type
+// is the type of service; a missing service is an ErrMissingService, unless the tag is
+// `service:"optional"`, in which case the field is left as it is.
+type Services struct {
+	services map[reflect.Type]interface{}
+	lock     *sync.RWMutex
+}
+
+// NewServices returns a new empty service registry
+func NewServices() *Services {
+	return &Services{
+		services: make(map[reflect.Type]interface{}),
+		lock:     &sync.RWMutex{}}
+}
+
+// Register adds a service to the registry as type T, replacing any existing service of that type.
+// T is usually an interface the service implements; the service must be assignable to it.
+func (services *Services) Register(T reflect.Type, service interface{}) error {
+	if service == nil {
+		return errors.Fail(ErrNullValue{}, nil, fmt.Sprintf("Cannot register a nil service as %s", typeName(T)))
+	}
+	if !reflect.TypeOf(service).AssignableTo(T) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Cannot register %s as service %s", typeName(reflect.TypeOf(service)), typeName(T)))
+	}
+	services.lock.Lock()
+	defer services.lock.Unlock()
+	services.services[T] = service
+	return nil
+}
+
+// Get returns the service registered as type T, or an ErrMissingService.
+func (services *Services) Get(T reflect.Type) (interface{}, error) {
+	services.lock.RLock()
+	service, ok := services.services[T]
+	services.lock.RUnlock()
+	if !ok {
+		return nil, errors.Fail(ErrMissingService{}, nil, fmt.Sprintf("No service %s is registered", typeName(T)))
+	}
+	return service, nil
+}
+
+// RegisterService adds a service to the registry as type T; see Services.Register.
+func RegisterService[T any](services *Services, service T) error {
+	return services.Register(reflect.TypeOf((*T)(nil)).Elem(), service)
+}
+
+// Service returns the service of type T from the runtime of the context, or an ErrMissingService.
+func Service[T any](context *Context) (T, error) {
+	var none T
+	service, err := context.Service(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return none, err
+	}
+	return service.(T), nil
+}
+
+// Service returns the service registered as type T with the runtime of the context, or an
+// ErrMissingService.
+func (context *Context) Service(T reflect.Type) (interface{}, error) {
+	if context.Runtime == nil {
+		return nil, errors.Fail(ErrMissingService{}, nil, fmt.Sprintf("No service %s; the object is not in a runtime", typeName(T)))
+	}
+	return context.Runtime.services.Get(T)
+}
+
+// Services returns the service registry of the runtime
+func (runtime *Runtime) Services() *Services {
+	return runtime.services
+}
+
+// UseServices sets the services injected into the components the factory deserializes. A runtime
+// sets its own services on a factory that has none.
+func (factory *ObjectFactory) UseServices(services *Services) {
+	factory.services = services
+}
+
+// serviceField is a field of a component that a service is injected into
+type serviceField struct {
+	Index    int
+	Name     string
+	Type     reflect.Type
+	Optional bool
+}
+
+// serviceFields caches the service fields of each component type
+var serviceFields = &sync.Map{}
+
+// fieldsForServices returns the fields of a component type that have a service tag
+func fieldsForServices(T reflect.Type) []serviceField {
+	if cached, ok := serviceFields.Load(T); ok {
+		return cached.([]serviceField)
+	}
+	fields := make([]serviceField, 0)
+	if T.Kind() == reflect.Ptr && T.Elem().Kind() == reflect.Struct {
+		structType := T.Elem()
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			tag, ok := field.Tag.Lookup("service")
+			if !ok {
+				continue
+			}
+			fields = append(fields, serviceField{
+				Index:    i,
+				Name:     field.Name,
+				Type:     field.Type,
+				Optional: strings.TrimSpace(tag) == "optional"})
+		}
+	}
+	serviceFields.Store(T, fields)
+	return fields
+}
+
+// injectServices sets the tagged service fields of a component from the registry
+func injectServices(component Component, services *Services) error {
+	value := reflect.ValueOf(component)
+	fields := fieldsForServices(value.Type())
+	if len(fields) == 0 || services == nil {
+		return nil
+	}
+	target := value.Elem()
+	for i := 0; i < len(fields); i++ {
+		field := target.Field(fields[i].Index)
+		if !field.CanSet() {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Cannot inject service %s into unexported field %s of component %s", typeName(fields[i].Type), fields[i].Name, typeName(component.Type())))
+		}
+		service, err := services.Get(fields[i].Type)
+		if err != nil {
+			if fields[i].Optional {
+				continue
+			}
+			return errors.Fail(ErrMissingService{}, err, fmt.Sprintf("Component %s requires service %s for field %s", typeName(component.Type()), typeName(fields[i].Type), fields[i].Name))
+		}
+		field.Set(reflect.ValueOf(service))
+	}
+	return nil
+}
+
+// injectTree injects services into the components of an object and all its children
+func injectTree(object *Object, services *Services) error {
+	components := object.components
+	for i := 0; i < len(components); i++ {
+		if err := injectServices(components[i].Component, services); err != nil {
+			return err
+		}
+	}
+	children := object.children
+	for i := 0; i < len(children); i++ {
+		if err := injectTree(children[i], services); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// injectEntering injects the services of the parent's runtime into an object that is about to be
+// added to it, if the object is entering that runtime
+func injectEntering(parent *Object, object *Object) error {
+	runtime := parent.Runtime()
+	if runtime == nil || object.Runtime() == runtime {
+		return nil
+	}
+	return injectTree(object, runtime.services)
+}
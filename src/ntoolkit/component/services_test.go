@@ -0,0 +1,136 @@
+package component_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"reflect"
+	"testing"
+)
+
+func TestServices(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		services := component.NewServices()
+		T.Assert(component.RegisterService[FakeRandom](services, &FakeFixedRandom{Value: 4}) == nil)
+		T.Assert(errors.Is(services.Register(reflect.TypeOf((*FakeRandom)(nil)).Elem(), &FakeSettings{}), component.ErrBadValue{}))
+		runtime := component.NewRuntime(component.Config{Services: services})
+		T.Assert(runtime.Services() == services)
+
+		obj := component.NewObject("Dice")
+		runtime.Root().AddObject(obj)
+		fake := &FakeServiceComponent{}
+		T.Assert(obj.AddComponent(fake) == nil)
+		T.Assert(fake.Random != nil)
+		T.Assert(fake.Settings == nil)
+
+		runtime.Update(1)
+		T.Assert(fake.Rolled == 4)
+		T.Assert(fake.Looked == 4)
+
+		_, err := obj.NewContext(0).Service(reflect.TypeOf(&FakeSettings{}))
+		T.Assert(errors.Is(err, component.ErrMissingService{}))
+	})
+}
+
+func TestMissingServices(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		runtime.Factory().Register(&FakeServiceComponent{})
+
+		obj := component.NewObject("Dice")
+		runtime.Root().AddObject(obj)
+		T.Assert(errors.Is(obj.AddComponent(&FakeServiceComponent{}), component.ErrMissingService{}))
+		_, found := component.GetComponent[*FakeServiceComponent](obj)
+		T.Assert(!found)
+
+		// Objects outside a runtime are injected when they are added to one
+		outside := component.NewObject("Outside")
+		T.Assert(outside.AddComponent(&FakeServiceComponent{}) == nil)
+		T.Assert(errors.Is(runtime.Root().AddObject(outside), component.ErrMissingService{}))
+		T.Assert(outside.Parent() == nil)
+
+		template := &component.ObjectTemplate{
+			Name:       "Loaded",
+			Components: []component.ComponentTemplate{{Type: "*ntoolkit/component_test.FakeServiceComponent"}}}
+		_, err := runtime.Factory().Deserialize(template)
+		T.Assert(errors.Is(err, component.ErrMissingService{}))
+		raw, _ := component.ObjectTemplateAsJson(template)
+		_, err = runtime.Factory().NewDecoder(bytes.NewReader(raw)).Decode(context.Background())
+		T.Assert(errors.Is(err, component.ErrMissingService{}))
+
+		component.RegisterService[FakeRandom](runtime.Services(), &FakeFixedRandom{Value: 2})
+		loaded, err := runtime.Factory().Deserialize(template)
+		T.Assert(err == nil)
+		fake, _ := component.GetComponent[*FakeServiceComponent](loaded)
+		T.Assert(fake.Random.Next() == 2)
+		T.Assert(runtime.Root().AddObject(outside) == nil)
+
+		decoded, err := runtime.Factory().NewDecoder(bytes.NewReader(raw)).Decode(context.Background())
+		T.Assert(err == nil)
+		fake, _ = component.GetComponent[*FakeServiceComponent](decoded)
+		T.Assert(fake.Random.Next() == 2)
+	})
+}
+
+func TestReplicatedServices(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		serverRuntime := component.NewRuntime(component.Config{})
+		serverRuntime.Factory().Register(&FakeServiceComponent{})
+		component.RegisterService[FakeRandom](serverRuntime.Services(), &FakeFixedRandom{Value: 1})
+		server := component.NewReplicationServer(serverRuntime)
+		dice := component.NewObject("Dice")
+		dice.AddComponent(&component.Replicated{})
+		serverRuntime.Root().AddObject(dice)
+
+		conns := make([]net.Conn, 0)
+		defer (func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		})()
+		connect := func(services *component.Services) (*component.Runtime, *component.ReplicationClient) {
+			clientRuntime := component.NewRuntime(component.Config{Services: services})
+			clientRuntime.Factory().Register(&FakeServiceComponent{})
+			serverConn, clientConn := net.Pipe()
+			conns = append(conns, serverConn, clientConn)
+			server.Connect(serverConn).Watch(dice)
+			return clientRuntime, component.NewReplicationClient(clientRuntime, clientConn)
+		}
+		services := component.NewServices()
+		component.RegisterService[FakeRandom](services, &FakeFixedRandom{Value: 3})
+		_, missing := connect(nil)
+		clientRuntime, client := connect(services)
+
+		results := make(chan error, 2)
+		receive := func(client *component.ReplicationClient) {
+			go (func() {
+				results <- client.Receive()
+			})()
+		}
+		receive(missing)
+		receive(client)
+		T.Assert(server.Tick() == nil)
+		T.Assert(<-results == nil)
+		T.Assert(<-results == nil)
+
+		// Components added to replicated objects are injected like any other
+		T.Assert(dice.AddComponent(&FakeServiceComponent{}) == nil)
+		receive(missing)
+		receive(client)
+		T.Assert(server.Tick() == nil)
+		first, second := <-results, <-results
+		if first == nil {
+			first, second = second, first
+		}
+		T.Assert(errors.Is(first, component.ErrMissingService{}))
+		T.Assert(second == nil)
+
+		remote, err := clientRuntime.Root().FindObject("Dice")
+		T.Assert(err == nil)
+		fake, _ := component.GetComponent[*FakeServiceComponent](remote)
+		T.Assert(fake != nil && fake.Random.Next() == 3)
+	})
+}
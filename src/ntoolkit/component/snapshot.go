@@ -0,0 +1,157 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ntoolkit/errors"
+)
+
+// Snapshot is a serializable copy of the entire state of a runtime.
+type Snapshot struct {
+	Root       ObjectTemplate // The root object, its components and all its children
+	Active     []int          // The number of frames each component has been active, in depth first order
+	TimeScales []float32      `json:",omitempty"` // The time scale of each object, in depth first order
+}
+
+// SnapshotFromJson loads a snapshot from a json block.
+func SnapshotFromJson(raw string) (*Snapshot, error) {
+	var data Snapshot
+	err := json.Unmarshal([]byte(raw), &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// SnapshotAsJson saves a snapshot as a json block.
+func SnapshotAsJson(snapshot *Snapshot) ([]byte, error) {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Snapshot captures the whole runtime, including the root object, the activation state of every
+// component and the time scale of every object. It waits for the current Update() to finish; do
+// not call it from a component or a scheduled task.
+func (runtime *Runtime) Snapshot() (*Snapshot, error) {
+	runtime.updateLock.Lock()
+	defer runtime.updateLock.Unlock()
+
+	template, err := runtime.factory.Serialize(runtime.root)
+	if err != nil {
+		return nil, err
+	}
+	rtn := &Snapshot{Root: *template, Active: make([]int, 0), TimeScales: make([]float32, 0)}
+	collectActive(runtime.root, &rtn.Active)
+	collectTimeScales(runtime.root, &rtn.TimeScales)
+	return rtn, nil
+}
+
+// Restore replaces the whole runtime with the contents of a snapshot.
+// The new object tree is fully built before it atomically replaces the existing one between frames,
+// so if restoring fails the runtime is unchanged. Objects keep the ids and time scales they had in the
+// snapshot, and components that had already started in the snapshot are not started again. Once the
+// new tree is in place the replaced one is destroyed, as by Object.Destroy; any OnDestroy failures are
+// logged rather than returned, as the snapshot has been restored. A closed runtime cannot be restored.
+func (runtime *Runtime) Restore(snapshot *Snapshot) error {
+	if snapshot == nil {
+		return errors.Fail(ErrNullValue{}, nil, "Invalid snapshot")
+	}
+	if runtime.Closed() {
+		return errors.Fail(ErrClosed{}, nil, "Unable to restore; the runtime is closed")
+	}
+
+	root, err := runtime.factory.deserialize(&snapshot.Root, true)
+	if err != nil {
+		runtime.logger.Warn("Failed to restore snapshot", "error", err)
+		return err
+	}
+
+	offset := 0
+	if err := restoreActive(root, snapshot.Active, &offset); err != nil {
+		return err
+	}
+	if offset != len(snapshot.Active) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Snapshot has %d activation states for %d components", len(snapshot.Active), offset))
+	}
+	if len(snapshot.TimeScales) > 0 {
+		offset = 0
+		if err := restoreTimeScales(root, snapshot.TimeScales, &offset); err != nil {
+			return err
+		}
+		if offset != len(snapshot.TimeScales) {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Snapshot has %d time scales for %d objects", len(snapshot.TimeScales), offset))
+		}
+	}
+
+	runtime.updateLock.Lock()
+	defer runtime.updateLock.Unlock()
+	if runtime.Closed() {
+		return errors.Fail(ErrClosed{}, nil, "Unable to restore; the runtime is closed")
+	}
+	previous := runtime.root
+	root.runtime = runtime
+	runtime.root = root
+	runtime.rebuildIndex()
+	if failures := previous.destroy(runtime, nil); len(failures) > 0 {
+		runtime.logger.Error("Failed to destroy the replaced objects", "error", destroyFailed(failures, "Failed to destroy the replaced objects"), "failures", len(failures))
+	}
+	forgetRuntime([]*Object{previous})
+	return nil
+}
+
+// collectActive appends the activation state of every component in the tree, depth first
+func collectActive(object *Object, active *[]int) {
+	for i := 0; i < len(object.components); i++ {
+		*active = append(*active, object.components[i].Active)
+	}
+	for i := 0; i < len(object.children); i++ {
+		collectActive(object.children[i], active)
+	}
+}
+
+// collectTimeScales appends the time scale of every object in the tree, depth first
+func collectTimeScales(object *Object, scales *[]float32) {
+	*scales = append(*scales, object.timeScale)
+	for i := 0; i < len(object.children); i++ {
+		collectTimeScales(object.children[i], scales)
+	}
+}
+
+// restoreTimeScales assigns the time scale of every object in the tree, depth first
+func restoreTimeScales(object *Object, scales []float32, offset *int) error {
+	if *offset >= len(scales) {
+		return errors.Fail(ErrBadValue{}, nil, "Snapshot is missing object time scales")
+	}
+	if scale := scales[*offset]; !validTimeScale(scale) {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid time scale %v in snapshot", scale))
+	}
+	object.timeScale = scales[*offset]
+	*offset += 1
+	for i := 0; i < len(object.children); i++ {
+		if err := restoreTimeScales(object.children[i], scales, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreActive assigns the activation state of every component in the tree, depth first
+func restoreActive(object *Object, active []int, offset *int) error {
+	for i := 0; i < len(object.components); i++ {
+		if *offset >= len(active) {
+			return errors.Fail(ErrBadValue{}, nil, "Snapshot is missing component activation states")
+		}
+		object.components[i].Active = active[*offset]
+		*offset += 1
+	}
+	for i := 0; i < len(object.children); i++ {
+		if err := restoreActive(object.children[i], active, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package component_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+func TestSnapshotRestore(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		runtime.Factory().Register(&FakeStartComponent{})
+
+		runtime.Root().AddComponent(&FakeStartComponent{})
+		child := component.NewObject("Child")
+		child.AddComponent(&FakeStartComponent{})
+		runtime.Root().AddObject(child)
+
+		runtime.Update(1.0)
+		runtime.Update(1.0)
+
+		snapshot, err := runtime.Snapshot()
+		T.Assert(err == nil)
+		T.Assert(len(snapshot.Active) == 2)
+		T.Assert(snapshot.Active[0] == 2)
+
+		raw, err := component.SnapshotAsJson(snapshot)
+		T.Assert(err == nil)
+		snapshot, err = component.SnapshotFromJson(string(raw))
+		T.Assert(err == nil)
+
+		runtime.Root().RemoveObject(child)
+		T.Assert(runtime.Restore(snapshot) == nil)
+
+		runtime.Update(1.0)
+
+		var restored *FakeStartComponent
+		T.Assert(runtime.Root().Find(&restored) == nil)
+		T.Assert(restored.Starts == 1)
+		T.Assert(restored.Updates == 2)
+
+		T.Assert(runtime.Root().Find(&restored, "Child") == nil)
+		T.Assert(restored.Starts == 1)
+		T.Assert(restored.Updates == 2)
+	})
+}
+
+func TestRestoreKeepsIdentity(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		runtime.Factory().Register(&FakeStartComponent{})
+		child := component.NewObject("Child")
+		child.AddComponent(&FakeStartComponent{})
+		runtime.Root().AddObject(child)
+		T.Assert(child.SetTimeScale(0.5) == nil)
+
+		snapshot, err := runtime.Snapshot()
+		T.Assert(err == nil)
+		T.Assert(runtime.Restore(snapshot) == nil)
+
+		restored, err := runtime.Root().FindObject("Child")
+		T.Assert(err == nil)
+		T.Assert(restored != child)
+		T.Assert(restored.ID() == child.ID())
+		T.Assert(restored.TimeScale() == 0.5)
+	})
+}
+
+func TestRestoreDestroysReplacedObjects(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		runtime.Factory().Register(&FakeStartComponent{})
+		snapshot, err := runtime.Snapshot()
+		T.Assert(err == nil)
+
+		destroy := &FakeDestroyComponent{}
+		timers := &FakeTimerComponent{}
+		child := component.NewObject("Child")
+		child.AddComponent(destroy)
+		child.AddComponent(timers)
+		runtime.Root().AddObject(child)
+		runtime.Update(1)
+		T.Assert(timers.Every != nil)
+
+		T.Assert(runtime.Restore(snapshot) == nil)
+		T.Assert(destroy.Destroys == 1)
+		T.Assert(timers.Every.Cancelled())
+		T.Assert(child.Runtime() == nil)
+
+		T.Assert(runtime.Close(context.Background()) == nil)
+		T.Assert(errors.Is(runtime.Restore(snapshot), component.ErrClosed{}))
+	})
+}
+
+func TestRestoreInvalidSnapshot(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		runtime.Factory().Register(&FakeStartComponent{})
+		original := runtime.Root()
+
+		snapshot := &component.Snapshot{
+			Root:   component.ObjectTemplate{Components: []component.ComponentTemplate{{Type: "*ntoolkit/component_test.FakeStartComponent"}}},
+			Active: []int{1, 1}}
+
+		T.Assert(errors.Is(runtime.Restore(snapshot), component.ErrBadValue{}))
+		T.Assert(runtime.Root() == original)
+
+		// Time scales must be finite
+		for _, scale := range []float32{-1, float32(math.NaN()), float32(math.Inf(1))} {
+			snapshot = &component.Snapshot{Root: component.ObjectTemplate{}, TimeScales: []float32{scale}}
+			T.Assert(errors.Is(runtime.Restore(snapshot), component.ErrBadValue{}))
+			T.Assert(runtime.Root() == original)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package component
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"ntoolkit/errors"
+)
+
+// System is logic that runs once a frame over every object with a set of component types, rather
+// than in the Update of each component.
+type System interface {
+	// Access returns the component types the system reads and writes. It is only called when the
+	// system is added, so it should always return the same types.
+	Access() SystemAccess
+
+	// Run is invoked once a frame with the objects that have an enabled component of every type in
+	// the access.
+	Run(context *SystemContext)
+}
+
+// SystemAccess declares the component types a system uses. A system may only change the components
+// it writes; other systems that read or write those types never run at the same time.
+type SystemAccess struct {
+	Reads  []reflect.Type
+	Writes []reflect.Type
+}
+
+// SystemOptions configures how a system is added to a runtime.
+type SystemOptions struct {
+	AllowConflicts bool // Add the system even if its access conflicts; it runs after the systems it conflicts with
+}
+
+// SystemContext is the state a system runs with each frame.
+type SystemContext struct {
+	Objects   []*Object    // The active objects with an enabled component of every type the system accesses, in no particular order.
+	DeltaTime float32      // The delta step for the update; scale it by Object.EffectiveTimeScale for object time.
+	Logger    *slog.Logger // The runtime logger, annotated with the system type.
+	Runtime   *Runtime
+}
+
+// systemInfo is a system added to a runtime
+type systemInfo struct {
+	System System
+	Access SystemAccess
+	Types  []reflect.Type // Every type in the access, once each
+	Stage  int            // Systems in the same stage do not conflict, and run at the same time
+	Logger *slog.Logger   // The runtime logger, annotated with the system type
+}
+
+// systemSet is the systems of a runtime; it is replaced rather than modified so a frame can run it
+// without holding the lock.
+type systemSet struct {
+	systems []*systemInfo
+	stages  int
+}
+
+// AddSystem adds a system to the runtime, to run every frame after the components are updated.
+// Systems that neither write a type the other reads or writes run in parallel on the worker pool.
+// If the access of the system conflicts with one already added, an ErrAccessConflict is returned and
+// the system is not added, unless AllowConflicts is set; then it runs after the systems it conflicts
+// with, in the order they were added.
+func (runtime *Runtime) AddSystem(system System, options ...SystemOptions) error {
+	if system == nil {
+		return errors.Fail(ErrNullValue{}, nil, "Cannot add a nil system")
+	}
+	option := SystemOptions{}
+	if len(options) > 0 {
+		option = options[0]
+	}
+	info := &systemInfo{System: system, Access: system.Access(), Logger: runtime.logger.With("system", typeName(reflect.TypeOf(system)))}
+	info.Types = accessTypes(info.Access)
+	if len(info.Types) == 0 {
+		return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("System %s does not access any component types", typeName(reflect.TypeOf(system))))
+	}
+
+	runtime.systemsLock.Lock()
+	defer runtime.systemsLock.Unlock()
+	current := runtime.systems
+	for i := 0; i < len(current.systems); i++ {
+		if current.systems[i].System == system {
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("System %s has already been added", typeName(reflect.TypeOf(system))))
+		}
+		if T := accessConflict(current.systems[i].Access, info.Access); T != nil && !option.AllowConflicts {
+			return errors.Fail(ErrAccessConflict{}, nil, fmt.Sprintf("System %s conflicts with system %s over %s", typeName(reflect.TypeOf(system)), typeName(reflect.TypeOf(current.systems[i].System)), typeName(T)))
+		}
+	}
+	runtime.systems = newSystemSet(append(current.systems[:len(current.systems):len(current.systems)], info))
+	return nil
+}
+
+// RemoveSystem removes a system from the runtime.
+func (runtime *Runtime) RemoveSystem(system System) error {
+	runtime.systemsLock.Lock()
+	defer runtime.systemsLock.Unlock()
+	current := runtime.systems
+	for i := 0; i < len(current.systems); i++ {
+		if current.systems[i].System == system {
+			systems := make([]*systemInfo, 0, len(current.systems)-1)
+			systems = append(systems, current.systems[:i]...)
+			runtime.systems = newSystemSet(append(systems, current.systems[i+1:]...))
+			return nil
+		}
+	}
+	return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for system %s", typeName(reflect.TypeOf(system))))
+}
+
+// newSystemSet assigns stages to systems in the order they were added; each system runs in the stage
+// after the last one it conflicts with.
+func newSystemSet(systems []*systemInfo) *systemSet {
+	rtn := &systemSet{systems: make([]*systemInfo, len(systems))}
+	for i := 0; i < len(systems); i++ {
+		info := *systems[i]
+		info.Stage = 0
+		for j := 0; j < i; j++ {
+			if accessConflict(rtn.systems[j].Access, info.Access) != nil && rtn.systems[j].Stage >= info.Stage {
+				info.Stage = rtn.systems[j].Stage + 1
+			}
+		}
+		if info.Stage+1 > rtn.stages {
+			rtn.stages = info.Stage + 1
+		}
+		rtn.systems[i] = &info
+	}
+	return rtn
+}
+
+// runSystems runs every system, stage by stage, on the worker pool
+func (runtime *Runtime) runSystems(step float32) {
+	runtime.systemsLock.Lock()
+	set := runtime.systems
+	runtime.systemsLock.Unlock()
+	for stage := 0; stage < set.stages; stage++ {
+		for i := 0; i < len(set.systems); i++ {
+			if set.systems[i].Stage == stage {
+				runtime.runSystem(step, set.systems[i])
+			}
+		}
+		runtime.workers.Wait()
+	}
+}
+
+// runSystem runs a single system in a worker thread
+func (runtime *Runtime) runSystem(step float32, info *systemInfo) {
+	runtime.workers.Run(func() {
+		objects := make([]*Object, 0)
+		results := runtime.queryComponents(info.Types)
+		for i := 0; i < len(results); i++ {
+			for _, object := range results[i].objects {
+				if object.ActiveInHierarchy() && !object.Paused() && object.hasEnabled(info.Types) {
+					objects = append(objects, object)
+				}
+			}
+		}
+		info.System.Run(&SystemContext{
+			Objects:   objects,
+			DeltaTime: step,
+			Logger:    info.Logger,
+			Runtime:   runtime})
+	})
+}
+
+// hasEnabled checks if the object has an enabled component of each of the given types
+func (o *Object) hasEnabled(types []reflect.Type) bool {
+	components := o.components
+	for _, T := range types {
+		found := false
+		for i := 0; i < len(components) && !found; i++ {
+			if !components[i].Disabled && (components[i].Type == T || (T.Kind() == reflect.Interface && components[i].Type.Implements(T))) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// accessTypes returns every type in an access, once each
+func accessTypes(access SystemAccess) []reflect.Type {
+	rtn := make([]reflect.Type, 0, len(access.Reads)+len(access.Writes))
+	seen := make(map[reflect.Type]bool)
+	for _, types := range [][]reflect.Type{access.Writes, access.Reads} {
+		for i := 0; i < len(types); i++ {
+			if !seen[types[i]] {
+				seen[types[i]] = true
+				rtn = append(rtn, types[i])
+			}
+		}
+	}
+	return rtn
+}
+
+// accessConflict returns a type that one access writes and the other reads or writes, or nil
+func accessConflict(a SystemAccess, b SystemAccess) reflect.Type {
+	if T := typesOverlap(a.Writes, accessTypes(b)); T != nil {
+		return T
+	}
+	return typesOverlap(b.Writes, accessTypes(a))
+}
+
+// typesOverlap returns a type in a that may refer to the same components as a type in b, or nil.
+// An interface overlaps with the types that implement it.
+func typesOverlap(a []reflect.Type, b []reflect.Type) reflect.Type {
+	for i := 0; i < len(a); i++ {
+		for j := 0; j < len(b); j++ {
+			if a[i] == b[j] {
+				return a[i]
+			}
+			if a[i].Kind() == reflect.Interface && b[j].Implements(a[i]) {
+				return a[i]
+			}
+			if b[j].Kind() == reflect.Interface && a[i].Implements(b[j]) {
+				return a[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package component_test
+
+import (
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"ntoolkit/errors"
+	"testing"
+)
+
+func TestSystems(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		buildMovers(runtime, 10)
+		log := &FakeSystemLog{}
+		move := &FakeMoveSystem{Log: log}
+		T.Assert(runtime.AddSystem(move) == nil)
+		T.Assert(errors.Is(runtime.AddSystem(move), component.ErrBadValue{}))
+
+		runtime.Update(0.5)
+		runtime.Update(0.5)
+		T.Assert(move.Runs == 2)
+		obj, _ := runtime.Root().GetObject("Mover 0")
+		position, _ := component.GetComponent[*FakePosition](obj)
+		T.Assert(position.X == 1)
+		T.Assert(position.Y == 2)
+
+		// Inactive objects are skipped
+		obj.SetActive(false)
+		runtime.Update(1)
+		T.Assert(position.X == 1)
+
+		// So are objects where a matching component is disabled
+		other, _ := runtime.Root().GetObject("Mover 2")
+		otherPosition, _ := component.GetComponent[*FakePosition](other)
+		velocity, _ := component.GetComponent[*FakeVelocity](other)
+		moved := otherPosition.X
+		T.Assert(other.SetEnabled(velocity, false) == nil)
+		runtime.Update(1)
+		T.Assert(otherPosition.X == moved)
+		T.Assert(other.SetEnabled(velocity, true) == nil)
+		runtime.Update(1)
+		T.Assert(otherPosition.X == moved+1)
+
+		T.Assert(runtime.RemoveSystem(move) == nil)
+		T.Assert(errors.Is(runtime.RemoveSystem(move), component.ErrNoMatch{}))
+		runtime.Update(1)
+		T.Assert(move.Runs == 5)
+	})
+}
+
+func TestSystemConflicts(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{})
+		buildMovers(runtime, 10)
+		log := &FakeSystemLog{}
+		first := &FakeCountSystem{Name: "first", Log: log}
+		second := &FakeCountSystem{Name: "second", Log: log}
+		move := &FakeMoveSystem{Log: log}
+		after := &FakeCountSystem{Name: "after", Log: log}
+
+		// Readers do not conflict with each other
+		T.Assert(runtime.AddSystem(first) == nil)
+		T.Assert(runtime.AddSystem(second) == nil)
+		T.Assert(errors.Is(runtime.AddSystem(move), component.ErrAccessConflict{}))
+		T.Assert(runtime.AddSystem(move, component.SystemOptions{AllowConflicts: true}) == nil)
+		T.Assert(runtime.AddSystem(after, component.SystemOptions{AllowConflicts: true}) == nil)
+
+		runtime.Update(1)
+		T.Assert(len(log.Entries) == 4)
+		T.Assert(log.Entries[2] == "move")
+		T.Assert(log.Entries[3] == "after")
+		T.Assert(first.Count == 10)
+		T.Assert(second.Count == 10)
+	})
+}
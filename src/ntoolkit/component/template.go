@@ -8,14 +8,18 @@ import (
 // ObjectTemplate is a simple, flat, serializable object structure that directly converts to and from ObjectsInChildren.
 type ObjectTemplate struct {
 	Name       string
+	ID         string   `json:",omitempty"` // The id of the object this template was serialized from, if any
+	Tags       []string `json:",omitempty"` // The tags on the object, if any
+	Inactive   bool     `json:",omitempty"` // The object is not active; see Object.SetActive
 	Components []ComponentTemplate
 	Objects    []ObjectTemplate
 }
 
 // ComponentTemplate is a serializable representation of a component
 type ComponentTemplate struct {
-	Type string
-	Data interface{}
+	Type     string
+	Data     interface{}
+	Disabled bool `json:",omitempty"` // The component is not enabled; see Object.SetEnabled
 }
 
 // FromJson loads an object template from a json block.
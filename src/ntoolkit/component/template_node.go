@@ -0,0 +1,247 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ntoolkit/errors"
+)
+
+// templateNodeKind is the kind of value held by a templateNode.
+type templateNodeKind int
+
+const (
+	templateScalar templateNodeKind = iota
+	templateMapping
+	templateSequence
+)
+
+// templateNode is a parsed document value that remembers where it came from.
+// The text template loaders (yaml, toml) parse into nodes, and templateDecoder
+// turns the nodes into an ObjectTemplate so errors can point at the source.
+type templateNode struct {
+	kind   templateNodeKind
+	value  interface{}     // The decoded value of a scalar
+	raw    string          // The source text of a scalar
+	keys   []*templateNode // The keys of a mapping, in document order
+	values []*templateNode // The values of a mapping, or the items of a sequence
+	line   int
+	column int
+}
+
+// newTemplateScalar returns a new scalar node
+func newTemplateScalar(value interface{}, raw string, line int, column int) *templateNode {
+	return &templateNode{kind: templateScalar, value: value, raw: raw, line: line, column: column}
+}
+
+// newTemplateMapping returns a new empty mapping node
+func newTemplateMapping(line int, column int) *templateNode {
+	return &templateNode{kind: templateMapping, line: line, column: column}
+}
+
+// newTemplateSequence returns a new empty sequence node
+func newTemplateSequence(line int, column int) *templateNode {
+	return &templateNode{kind: templateSequence, line: line, column: column}
+}
+
+// get returns the value for a key in a mapping, or nil
+func (node *templateNode) get(key string) *templateNode {
+	for i := 0; i < len(node.keys); i++ {
+		if node.keys[i].value == key {
+			return node.values[i]
+		}
+	}
+	return nil
+}
+
+// set adds a key to a mapping; it is up to the caller to check for duplicates.
+func (node *templateNode) set(key *templateNode, value *templateNode) {
+	node.keys = append(node.keys, key)
+	node.values = append(node.values, value)
+}
+
+// plain converts the node into the same generic value encoding/json would produce.
+func (node *templateNode) plain() interface{} {
+	switch node.kind {
+	case templateMapping:
+		rtn := make(map[string]interface{}, len(node.keys))
+		for i := 0; i < len(node.keys); i++ {
+			rtn[node.keys[i].value.(string)] = node.values[i].plain()
+		}
+		return rtn
+	case templateSequence:
+		rtn := make([]interface{}, len(node.values))
+		for i := 0; i < len(node.values); i++ {
+			rtn[i] = node.values[i].plain()
+		}
+		return rtn
+	}
+	return node.value
+}
+
+// templateDecoder converts a node tree into an ObjectTemplate.
+// Field names are matched case insensitively and unknown fields are ignored, like encoding/json.
+type templateDecoder struct {
+	format string
+}
+
+// fail returns an ErrBadValue that points at the given node
+func (decoder *templateDecoder) fail(node *templateNode, message string) error {
+	return templateSyntaxError(decoder.format, node.line, node.column, message)
+}
+
+// templateSyntaxError returns an ErrBadValue for an invalid template document
+func templateSyntaxError(format string, line int, column int, message string) error {
+	return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Invalid %s template at line %d, column %d: %s", format, line, column, message))
+}
+
+// objectTemplate converts a mapping node into an ObjectTemplate
+func (decoder *templateDecoder) objectTemplate(node *templateNode) (*ObjectTemplate, error) {
+	if node.kind != templateMapping {
+		return nil, decoder.fail(node, "Expected an object")
+	}
+	rtn := &ObjectTemplate{}
+	for i := 0; i < len(node.keys); i++ {
+		key := node.keys[i].value.(string)
+		value := node.values[i]
+		var err error
+		switch {
+		case strings.EqualFold(key, "Name"):
+			rtn.Name, err = decoder.stringValue(value)
+		case strings.EqualFold(key, "ID"):
+			rtn.ID, err = decoder.stringValue(value)
+		case strings.EqualFold(key, "Tags"):
+			rtn.Tags, err = decoder.stringValues(value)
+		case strings.EqualFold(key, "Inactive"):
+			rtn.Inactive, err = decoder.boolValue(value)
+		case strings.EqualFold(key, "Components"):
+			rtn.Components, err = decoder.componentTemplates(value)
+		case strings.EqualFold(key, "Objects"):
+			rtn.Objects, err = decoder.objectTemplates(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rtn, nil
+}
+
+// objectTemplates converts a sequence node into a set of ObjectTemplates
+func (decoder *templateDecoder) objectTemplates(node *templateNode) ([]ObjectTemplate, error) {
+	if node.kind == templateScalar && node.value == nil {
+		return nil, nil
+	}
+	if node.kind != templateSequence {
+		return nil, decoder.fail(node, "Expected a list of objects")
+	}
+	rtn := make([]ObjectTemplate, 0, len(node.values))
+	for i := 0; i < len(node.values); i++ {
+		obj, err := decoder.objectTemplate(node.values[i])
+		if err != nil {
+			return nil, err
+		}
+		rtn = append(rtn, *obj)
+	}
+	return rtn, nil
+}
+
+// componentTemplates converts a sequence node into a set of ComponentTemplates
+func (decoder *templateDecoder) componentTemplates(node *templateNode) ([]ComponentTemplate, error) {
+	if node.kind == templateScalar && node.value == nil {
+		return nil, nil
+	}
+	if node.kind != templateSequence {
+		return nil, decoder.fail(node, "Expected a list of components")
+	}
+	rtn := make([]ComponentTemplate, 0, len(node.values))
+	for i := 0; i < len(node.values); i++ {
+		item := node.values[i]
+		if item.kind != templateMapping {
+			return nil, decoder.fail(item, "Expected a component")
+		}
+		component := ComponentTemplate{}
+		for j := 0; j < len(item.keys); j++ {
+			key := item.keys[j].value.(string)
+			if strings.EqualFold(key, "Type") {
+				value, err := decoder.stringValue(item.values[j])
+				if err != nil {
+					return nil, err
+				}
+				component.Type = value
+			} else if strings.EqualFold(key, "Data") {
+				component.Data = item.values[j].plain()
+			} else if strings.EqualFold(key, "Disabled") {
+				value, err := decoder.boolValue(item.values[j])
+				if err != nil {
+					return nil, err
+				}
+				component.Disabled = value
+			}
+		}
+		rtn = append(rtn, component)
+	}
+	return rtn, nil
+}
+
+// stringValue converts a scalar node into a string; null is the empty string.
+func (decoder *templateDecoder) stringValue(node *templateNode) (string, error) {
+	if node.kind != templateScalar {
+		return "", decoder.fail(node, "Expected a string")
+	}
+	if node.value == nil {
+		return "", nil
+	}
+	if value, ok := node.value.(string); ok {
+		return value, nil
+	}
+	return node.raw, nil
+}
+
+// boolValue converts a scalar node into a bool; null is false.
+func (decoder *templateDecoder) boolValue(node *templateNode) (bool, error) {
+	if node.kind == templateScalar && node.value == nil {
+		return false, nil
+	}
+	value, ok := node.value.(bool)
+	if node.kind != templateScalar || !ok {
+		return false, decoder.fail(node, "Expected true or false")
+	}
+	return value, nil
+}
+
+// stringValues converts a sequence node into a set of strings; null is no strings.
+func (decoder *templateDecoder) stringValues(node *templateNode) ([]string, error) {
+	if node.kind == templateScalar && node.value == nil {
+		return nil, nil
+	}
+	if node.kind != templateSequence {
+		return nil, decoder.fail(node, "Expected a list of strings")
+	}
+	rtn := make([]string, 0, len(node.values))
+	for i := 0; i < len(node.values); i++ {
+		value, err := decoder.stringValue(node.values[i])
+		if err != nil {
+			return nil, err
+		}
+		rtn = append(rtn, value)
+	}
+	return rtn, nil
+}
+
+// plainTemplateData converts component data into generic maps, lists and scalars,
+// exactly as if it had been written to json and read back.
+func plainTemplateData(data interface{}) (interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Fail(ErrBadValue{}, err, "Failed to re-encode data")
+	}
+	var rtn interface{}
+	if err := json.Unmarshal(bytes, &rtn); err != nil {
+		return nil, errors.Fail(ErrBadValue{}, err, "Failed to decode data")
+	}
+	return rtn, nil
+}
@@ -2,6 +2,7 @@ package component_test
 
 import (
 	"ntoolkit/assert"
+	"strings"
 	"testing"
 	c "ntoolkit/component"
 )
@@ -143,3 +144,15 @@ func TestNestedTemplateToObjectViaFactory(T *testing.T) {
 		T.Assert(cmp.Data.Items[2].Count == 3)
 	})
 }
+
+func TestTemplateJsonOmitsEmptyID(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		raw, err := c.ObjectTemplateAsJson(&c.ObjectTemplate{Name: "Plain"})
+		T.Assert(err == nil)
+		T.Assert(!strings.Contains(string(raw), "ID"))
+
+		raw, err = c.ObjectTemplateAsJson(&c.ObjectTemplate{Name: "Saved", ID: "1"})
+		T.Assert(err == nil)
+		T.Assert(strings.Contains(string(raw), `"ID":"1"`))
+	})
+}
@@ -0,0 +1,873 @@
+package component
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"ntoolkit/errors"
+)
+
+// ObjectTemplateFromToml loads an object template from a toml block.
+// Child objects and components are written as arrays of tables, eg. [[Objects]], [[Objects.Components]].
+// Dates and times are not supported, and all numbers are read as float64 to match ObjectTemplateFromJson.
+func ObjectTemplateFromToml(raw string) (*ObjectTemplate, error) {
+	parser := newTomlParser(raw)
+	node, err := parser.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	decoder := &templateDecoder{format: "toml"}
+	return decoder.objectTemplate(node)
+}
+
+// ObjectTemplateAsToml saves an object template as a toml block.
+// Toml has no null value, so a template without component data has no Data key, and null map
+// entries or array items fail with ErrNotSupported.
+func ObjectTemplateAsToml(template *ObjectTemplate) ([]byte, error) {
+	writer := &tomlWriter{}
+	if err := writer.writeObject(template, nil); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimLeft(writer.output.String(), "\n")), nil
+}
+
+// tomlParser is a parser for toml documents.
+type tomlParser struct {
+	src     []rune
+	pos     int
+	line    int
+	column  int
+	root    *templateNode
+	current *templateNode          // The table that key/value pairs are added to
+	defined map[*templateNode]bool // Tables defined by a [header]
+	frozen  map[*templateNode]bool // Inline tables and arrays, which cannot be extended
+	arrays  map[*templateNode]bool // Arrays defined by a [[header]]
+}
+
+var tomlBareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var tomlIntPattern = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+var tomlFloatPattern = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+var tomlDatePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}|^[0-9]{2}:[0-9]{2}`)
+
+// tomlKey is a single segment of a dotted key
+type tomlKey struct {
+	name   string
+	line   int
+	column int
+}
+
+// newTomlParser returns a parser for the given document
+func newTomlParser(raw string) *tomlParser {
+	raw = strings.Replace(raw, "\r\n", "\n", -1)
+	root := newTemplateMapping(1, 1)
+	return &tomlParser{
+		src:     []rune(raw),
+		line:    1,
+		column:  1,
+		root:    root,
+		current: root,
+		defined: make(map[*templateNode]bool),
+		frozen:  make(map[*templateNode]bool),
+		arrays:  make(map[*templateNode]bool)}
+}
+
+// fail returns an error at the current position
+func (p *tomlParser) fail(message string) error {
+	return templateSyntaxError("toml", p.line, p.column, message)
+}
+
+// failAt returns an error at the given key
+func (p *tomlParser) failAt(key tomlKey, message string) error {
+	return templateSyntaxError("toml", key.line, key.column, message)
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// peek returns the rune at the given offset from the cursor, or 0 past the end
+func (p *tomlParser) peek(offset ...int) rune {
+	i := p.pos
+	if len(offset) > 0 {
+		i += offset[0]
+	}
+	if i >= len(p.src) {
+		return 0
+	}
+	return p.src[i]
+}
+
+// next consumes a single rune
+func (p *tomlParser) next() rune {
+	r := p.src[p.pos]
+	p.pos += 1
+	if r == '\n' {
+		p.line += 1
+		p.column = 1
+	} else {
+		p.column += 1
+	}
+	return r
+}
+
+// lookingAt checks if the cursor is at the given text
+func (p *tomlParser) lookingAt(text string) bool {
+	i := 0
+	for _, r := range text {
+		if p.peek(i) != r {
+			return false
+		}
+		i += 1
+	}
+	return true
+}
+
+// skipInline skips spaces and tabs
+func (p *tomlParser) skipInline() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+}
+
+// skipSpace skips whitespace, line breaks and comments
+func (p *tomlParser) skipSpace() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\n':
+			p.next()
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endLine checks that nothing but a comment follows on the current line
+func (p *tomlParser) endLine() error {
+	p.skipInline()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.next()
+		}
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.fail(fmt.Sprintf("Expected the end of the line, found '%c'", p.peek()))
+	}
+	return nil
+}
+
+// parseDocument parses the whole input into the root table
+func (p *tomlParser) parseDocument() (*templateNode, error) {
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+	}
+	return p.root, nil
+}
+
+// parseHeader parses a [table] or [[array]] header and makes it the current table
+func (p *tomlParser) parseHeader() error {
+	array := p.lookingAt("[[")
+	p.next()
+	if array {
+		p.next()
+	}
+	p.skipInline()
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipInline()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.lookingAt(closing) {
+		return p.fail(fmt.Sprintf("Expected '%s'", closing))
+	}
+	for range closing {
+		p.next()
+	}
+
+	table := p.root
+	for i := 0; i < len(path)-1; i++ {
+		if table, err = p.descend(table, path[i]); err != nil {
+			return err
+		}
+	}
+	last := path[len(path)-1]
+	existing := table.get(last.name)
+
+	if array {
+		if existing == nil {
+			existing = newTemplateSequence(last.line, last.column)
+			p.arrays[existing] = true
+			table.set(newTemplateScalar(last.name, last.name, last.line, last.column), existing)
+		} else if !p.arrays[existing] {
+			return p.failAt(last, fmt.Sprintf("Key '%s' is already defined", last.name))
+		}
+		p.current = newTemplateMapping(last.line, last.column)
+		existing.values = append(existing.values, p.current)
+		return nil
+	}
+
+	if existing == nil {
+		existing = newTemplateMapping(last.line, last.column)
+		table.set(newTemplateScalar(last.name, last.name, last.line, last.column), existing)
+	} else if existing.kind != templateMapping || p.frozen[existing] {
+		return p.failAt(last, fmt.Sprintf("Key '%s' is already defined", last.name))
+	} else if p.defined[existing] {
+		return p.failAt(last, fmt.Sprintf("Table '%s' is already defined", last.name))
+	}
+	p.defined[existing] = true
+	p.current = existing
+	return nil
+}
+
+// descend returns the table for a key inside another table, creating it if required.
+// The last table of an array of tables is used, as per [[header]] semantics.
+func (p *tomlParser) descend(table *templateNode, key tomlKey) (*templateNode, error) {
+	existing := table.get(key.name)
+	if existing == nil {
+		existing = newTemplateMapping(key.line, key.column)
+		table.set(newTemplateScalar(key.name, key.name, key.line, key.column), existing)
+		return existing, nil
+	}
+	if p.arrays[existing] {
+		return existing.values[len(existing.values)-1], nil
+	}
+	if existing.kind != templateMapping || p.frozen[existing] {
+		return nil, p.failAt(key, fmt.Sprintf("Key '%s' is already defined as a value", key.name))
+	}
+	return existing, nil
+}
+
+// parseKeyValue parses a key = value pair into the given table
+func (p *tomlParser) parseKeyValue(table *templateNode) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipInline()
+	if p.peek() != '=' {
+		return p.fail("Expected '=' after key")
+	}
+	p.next()
+	p.skipInline()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(path)-1; i++ {
+		if table, err = p.descend(table, path[i]); err != nil {
+			return err
+		}
+	}
+	last := path[len(path)-1]
+	if table.get(last.name) != nil {
+		return p.failAt(last, fmt.Sprintf("Key '%s' is already defined", last.name))
+	}
+	table.set(newTemplateScalar(last.name, last.name, last.line, last.column), value)
+	return nil
+}
+
+// parseKey parses a bare, quoted or dotted key
+func (p *tomlParser) parseKey() ([]tomlKey, error) {
+	rtn := make([]tomlKey, 0, 1)
+	for {
+		key := tomlKey{line: p.line, column: p.column}
+		switch p.peek() {
+		case '"':
+			if p.lookingAt(`"""`) {
+				return nil, p.fail("Multi-line strings cannot be used as keys")
+			}
+			value, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key.name = value
+		case '\'':
+			if p.lookingAt("'''") {
+				return nil, p.fail("Multi-line strings cannot be used as keys")
+			}
+			value, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key.name = value
+		default:
+			start := p.pos
+			for !p.eof() && tomlBareKeyPattern.MatchString(string(p.peek())) {
+				p.next()
+			}
+			if p.pos == start {
+				return nil, p.fail("Expected a key")
+			}
+			key.name = string(p.src[start:p.pos])
+		}
+		rtn = append(rtn, key)
+		p.skipInline()
+		if p.peek() != '.' {
+			return rtn, nil
+		}
+		p.next()
+		p.skipInline()
+	}
+}
+
+// parseValue parses any value
+func (p *tomlParser) parseValue() (*templateNode, error) {
+	line, column := p.line, p.column
+	switch r := p.peek(); {
+	case p.eof() || r == '\n':
+		return nil, p.fail("Expected a value")
+	case p.lookingAt(`"""`):
+		value, err := p.parseMultilineString('"')
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case p.lookingAt("'''"):
+		value, err := p.parseMultilineString('\'')
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case r == '"':
+		value, err := p.parseBasicString()
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case r == '\'':
+		value, err := p.parseLiteralString()
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case r == '[':
+		return p.parseArray()
+	case r == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || strings.ContainsRune("+-._:", r)) {
+			break
+		}
+		p.next()
+	}
+	raw := string(p.src[start:p.pos])
+	if raw == "true" || raw == "false" {
+		return newTemplateScalar(raw == "true", raw, line, column), nil
+	}
+	value, err := parseTomlNumber(raw)
+	if err != nil {
+		return nil, templateSyntaxError("toml", line, column, err.Error())
+	}
+	return newTemplateScalar(value, raw, line, column), nil
+}
+
+// parseTomlNumber parses an integer or float; the result is always a float64, like encoding/json.
+func parseTomlNumber(raw string) (float64, error) {
+	switch strings.TrimPrefix(strings.TrimPrefix(raw, "+"), "-") {
+	case "inf", "nan":
+		return 0, fmt.Errorf("Infinite and NaN numbers are not supported; component data must be valid json")
+	}
+	if tomlDatePattern.MatchString(raw) {
+		return 0, fmt.Errorf("Dates and times are not supported")
+	}
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if strings.HasPrefix(raw, prefix) {
+			digits := raw[2:]
+			if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+				return 0, fmt.Errorf("Invalid number '%s'", raw)
+			}
+			value, err := strconv.ParseUint(strings.Replace(digits, "_", "", -1), base, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid number '%s'", raw)
+			}
+			return float64(value), nil
+		}
+	}
+	if !tomlIntPattern.MatchString(raw) && !tomlFloatPattern.MatchString(raw) {
+		if raw == "" {
+			return 0, fmt.Errorf("Expected a value")
+		}
+		return 0, fmt.Errorf("Invalid value '%s'", raw)
+	}
+	value, err := strconv.ParseFloat(strings.Replace(raw, "_", "", -1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid number '%s'", raw)
+	}
+	return value, nil
+}
+
+// parseArray parses an array value, which may span several lines
+func (p *tomlParser) parseArray() (*templateNode, error) {
+	rtn := newTemplateSequence(p.line, p.column)
+	p.frozen[rtn] = true
+	p.next() // '['
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.fail("Unterminated array")
+		}
+		if p.peek() == ']' {
+			p.next()
+			return rtn, nil
+		}
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		rtn.values = append(rtn.values, item)
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.next()
+		} else if p.peek() != ']' {
+			return nil, p.fail("Expected ',' or ']'")
+		}
+	}
+}
+
+// parseInlineTable parses a single line { key = value, ... } table
+func (p *tomlParser) parseInlineTable() (*templateNode, error) {
+	rtn := newTemplateMapping(p.line, p.column)
+	p.next() // '{'
+	p.skipInline()
+	if p.peek() == '}' {
+		p.next()
+		p.frozen[rtn] = true
+		return rtn, nil
+	}
+	for {
+		if err := p.parseKeyValue(rtn); err != nil {
+			return nil, err
+		}
+		p.skipInline()
+		if p.peek() == '}' {
+			p.next()
+			break
+		}
+		if p.peek() != ',' {
+			return nil, p.fail("Expected ',' or '}'")
+		}
+		p.next()
+		p.skipInline()
+	}
+	p.freeze(rtn)
+	return rtn, nil
+}
+
+// freeze marks an inline table, and any tables created by dotted keys inside it, as closed.
+func (p *tomlParser) freeze(node *templateNode) {
+	if node.kind == templateMapping {
+		p.frozen[node] = true
+		for i := 0; i < len(node.values); i++ {
+			p.freeze(node.values[i])
+		}
+	}
+}
+
+// parseBasicString parses a "double quoted" string with escapes
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.next() // '"'
+	rtn := &strings.Builder{}
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.fail("Unterminated string")
+		}
+		r := p.peek()
+		if r == '"' {
+			p.next()
+			return rtn.String(), nil
+		}
+		if r == '\\' {
+			if err := p.parseEscape(rtn); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := p.checkControl(r); err != nil {
+			return "", err
+		}
+		rtn.WriteRune(p.next())
+	}
+}
+
+// parseLiteralString parses a 'single quoted' string without escapes
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.next() // '\''
+	rtn := &strings.Builder{}
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.fail("Unterminated string")
+		}
+		r := p.peek()
+		if r == '\'' {
+			p.next()
+			return rtn.String(), nil
+		}
+		if err := p.checkControl(r); err != nil {
+			return "", err
+		}
+		rtn.WriteRune(p.next())
+	}
+}
+
+// parseMultilineString parses a multi-line basic or literal string, delimited by three quotes
+func (p *tomlParser) parseMultilineString(quote rune) (string, error) {
+	delimiter := strings.Repeat(string(quote), 3)
+	p.next()
+	p.next()
+	p.next()
+	if p.peek() == '\n' {
+		p.next()
+	}
+	rtn := &strings.Builder{}
+	for {
+		if p.eof() {
+			return "", p.fail("Unterminated multi-line string")
+		}
+		if p.lookingAt(delimiter) {
+			// Up to two quotes directly before the closing delimiter are part of the string
+			extra := 0
+			for extra < 2 && p.peek(3+extra) == quote {
+				extra += 1
+			}
+			for i := 0; i < extra; i++ {
+				rtn.WriteRune(p.next())
+			}
+			p.next()
+			p.next()
+			p.next()
+			return rtn.String(), nil
+		}
+		r := p.peek()
+		if quote == '"' && r == '\\' {
+			// A line ending backslash trims all whitespace up to the next non-whitespace character
+			i := 1
+			for p.peek(i) == ' ' || p.peek(i) == '\t' {
+				i += 1
+			}
+			if p.peek(i) == '\n' {
+				for p.peek() == '\\' || p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n' {
+					p.next()
+				}
+				continue
+			}
+			if err := p.parseEscape(rtn); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if r != '\n' {
+			if err := p.checkControl(r); err != nil {
+				return "", err
+			}
+		}
+		rtn.WriteRune(p.next())
+	}
+}
+
+// checkControl rejects control characters, which must be escaped in toml strings
+func (p *tomlParser) checkControl(r rune) error {
+	if (r < 0x20 && r != '\t') || r == 0x7f {
+		return p.fail("Control characters must be escaped")
+	}
+	return nil
+}
+
+// parseEscape decodes a single escape sequence starting at a '\'
+func (p *tomlParser) parseEscape(output *strings.Builder) error {
+	line, column := p.line, p.column
+	p.next() // '\\'
+	if p.eof() {
+		return p.fail("Unterminated string")
+	}
+	r := p.next()
+	simple := map[rune]string{'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b", '"': "\"", '\\': "\\"}
+	if value, ok := simple[r]; ok {
+		output.WriteString(value)
+		return nil
+	}
+	size := 0
+	switch r {
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return templateSyntaxError("toml", line, column, fmt.Sprintf("Invalid escape sequence '\\%c'", r))
+	}
+	digits := ""
+	for i := 0; i < size && !p.eof(); i++ {
+		digits += string(p.next())
+	}
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != size || !utf8.ValidRune(rune(value)) {
+		return templateSyntaxError("toml", line, column, fmt.Sprintf("Invalid escape sequence '\\%c%s'", r, digits))
+	}
+	output.WriteRune(rune(value))
+	return nil
+}
+
+// tomlWriter renders object templates as toml
+type tomlWriter struct {
+	output strings.Builder
+}
+
+// writeObject writes the keys of an object template into the table at path, followed by its
+// components and child objects as arrays of tables.
+func (writer *tomlWriter) writeObject(template *ObjectTemplate, path []string) error {
+	writer.output.WriteString(fmt.Sprintf("Name = %s\n", tomlString(template.Name)))
+	if template.ID != "" {
+		writer.output.WriteString(fmt.Sprintf("ID = %s\n", tomlString(template.ID)))
+	}
+	if len(template.Tags) > 0 {
+		tags := make([]string, len(template.Tags))
+		for i := 0; i < len(template.Tags); i++ {
+			tags[i] = tomlString(template.Tags[i])
+		}
+		writer.output.WriteString(fmt.Sprintf("Tags = [%s]\n", strings.Join(tags, ", ")))
+	}
+	if template.Inactive {
+		writer.output.WriteString("Inactive = true\n")
+	}
+	if template.Components != nil && len(template.Components) == 0 {
+		writer.output.WriteString("Components = []\n")
+	}
+	if template.Objects != nil && len(template.Objects) == 0 {
+		writer.output.WriteString("Objects = []\n")
+	}
+
+	componentsPath := append(append([]string{}, path...), "Components")
+	for i := 0; i < len(template.Components); i++ {
+		writer.output.WriteString(fmt.Sprintf("\n[[%s]]\n", tomlPath(componentsPath)))
+		writer.output.WriteString(fmt.Sprintf("Type = %s\n", tomlString(template.Components[i].Type)))
+		if template.Components[i].Disabled {
+			writer.output.WriteString("Disabled = true\n")
+		}
+		data, err := plainTemplateData(template.Components[i].Data)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		if table, ok := data.(map[string]interface{}); ok && len(table) > 0 {
+			if err := writer.writeTable(table, append(componentsPath, "Data"), false); err != nil {
+				return err
+			}
+		} else {
+			value, err := tomlValue(data)
+			if err != nil {
+				return err
+			}
+			writer.output.WriteString(fmt.Sprintf("Data = %s\n", value))
+		}
+	}
+
+	objectsPath := append(append([]string{}, path...), "Objects")
+	for i := 0; i < len(template.Objects); i++ {
+		writer.output.WriteString(fmt.Sprintf("\n[[%s]]\n", tomlPath(objectsPath)))
+		if err := writer.writeObject(&template.Objects[i], objectsPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable writes a table header followed by its keys; nested tables and arrays of tables follow as
+// their own sections.
+// A plain table that only holds sections is created implicitly by them, so its header is skipped.
+func (writer *tomlWriter) writeTable(table map[string]interface{}, path []string, array bool) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sections := make([]string, 0)
+	values := make([]string, 0)
+	for _, key := range keys {
+		value := table[key]
+		if isTomlSection(value) {
+			sections = append(sections, key)
+			continue
+		}
+		raw, err := tomlValue(value)
+		if err != nil {
+			return err
+		}
+		values = append(values, fmt.Sprintf("%s = %s\n", tomlKeyName(key), raw))
+	}
+
+	if array {
+		writer.output.WriteString(fmt.Sprintf("\n[[%s]]\n", tomlPath(path)))
+	} else if len(values) > 0 || len(sections) == 0 {
+		writer.output.WriteString(fmt.Sprintf("\n[%s]\n", tomlPath(path)))
+	}
+	for _, value := range values {
+		writer.output.WriteString(value)
+	}
+
+	for _, key := range sections {
+		childPath := append(append([]string{}, path...), key)
+		if child, ok := table[key].(map[string]interface{}); ok {
+			if err := writer.writeTable(child, childPath, false); err != nil {
+				return err
+			}
+			continue
+		}
+		items := table[key].([]interface{})
+		for _, item := range items {
+			if err := writer.writeTable(item.(map[string]interface{}), childPath, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTomlSection checks if a value is written as a [table] or [[array]] section rather than inline
+func isTomlSection(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		if len(v) == 0 {
+			return false
+		}
+		for _, item := range v {
+			if table, ok := item.(map[string]interface{}); !ok || len(table) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tomlValue renders a plain value inline
+func tomlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", errors.Fail(ErrNotSupported{}, nil, "Toml cannot represent null values")
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", errors.Fail(ErrNotSupported{}, nil, "Templates cannot hold infinite or NaN numbers")
+		} else if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		raw := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(raw, ".e") {
+			raw += ".0"
+		}
+		return raw, nil
+	case string:
+		return tomlString(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			raw, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, raw)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(v))
+		for _, key := range keys {
+			raw, err := tomlValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, fmt.Sprintf("%s = %s", tomlKeyName(key), raw))
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+	return "", errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Cannot write value of type %T as toml", value))
+}
+
+// tomlString renders a basic string with escapes
+func tomlString(value string) string {
+	rtn := &strings.Builder{}
+	rtn.WriteRune('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			rtn.WriteString(`\"`)
+		case '\\':
+			rtn.WriteString(`\\`)
+		case '\n':
+			rtn.WriteString(`\n`)
+		case '\r':
+			rtn.WriteString(`\r`)
+		case '\t':
+			rtn.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				rtn.WriteString(fmt.Sprintf(`\u%04X`, r))
+			} else {
+				rtn.WriteRune(r)
+			}
+		}
+	}
+	rtn.WriteRune('"')
+	return rtn.String()
+}
+
+// tomlKeyName renders a key, quoting it if it is not a valid bare key
+func tomlKeyName(key string) string {
+	if tomlBareKeyPattern.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlPath renders a dotted key path for a table header
+func tomlPath(path []string) string {
+	parts := make([]string, len(path))
+	for i := 0; i < len(path); i++ {
+		parts[i] = tomlKeyName(path[i])
+	}
+	return strings.Join(parts, ".")
+}
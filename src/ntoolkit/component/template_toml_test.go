@@ -0,0 +1,112 @@
+package component_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	c "ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+func TestTomlTemplateMatchesJson(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		fromJson, err := c.ObjectTemplateFromJson(objectTemplateSimple)
+		T.Assert(err == nil)
+
+		fromToml, err := c.ObjectTemplateFromToml(objectTemplateSimpleToml)
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(fromJson, fromToml))
+
+		fromJson, err = c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+
+		fromToml, err = c.ObjectTemplateFromToml(objectTemplateNestedToml)
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(fromJson, fromToml))
+	})
+}
+
+func TestTomlTemplateToObjectViaFactory(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+		factory.Register(&FakeConfiguredComponent{})
+
+		template, err := c.ObjectTemplateFromToml(objectTemplateNestedToml)
+		T.Assert(err == nil)
+
+		instance, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+
+		var cmp *FakeConfiguredComponent
+		err = instance.Find(&cmp, "One", "Two")
+		T.Assert(err == nil)
+		T.Assert(len(cmp.Data.Items) == 3)
+		T.Assert(cmp.Data.Items[2].Id == "3")
+		T.Assert(cmp.Data.Items[2].Count == 3)
+	})
+}
+
+func TestTomlTemplateRoundTrip(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		template, err := c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+		template.Name = "Name: with 'quotes' and \"escapes\"\n"
+		template.Components[0].Data = "Value,1"
+
+		raw, err := c.ObjectTemplateAsToml(template)
+		T.Assert(err == nil)
+
+		copy, err := c.ObjectTemplateFromToml(string(raw))
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(template, copy))
+	})
+}
+
+func TestTomlTemplateErrorPosition(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, err := c.ObjectTemplateFromToml("Name = \"One\"\n\n[[Components]]\nType = [1, 2\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 5, column 1"))
+
+		_, err = c.ObjectTemplateFromToml("Name = \"One\"\n[[Objects]]\nName = \"Two\"\n  Name = \"Three\"\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 3"))
+
+		_, err = c.ObjectTemplateFromToml("Name = \"One\"\n[[Objects]]\nName = [\"Two\"]\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 3, column 8"))
+
+		_, err = c.ObjectTemplateFromToml("Name = \"One\"\n[[Components]]\nType = \"Fake\"\nData = { Speed = -inf }\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 18"))
+
+		_, err = c.ObjectTemplateFromToml("Name = \"One\"\n[[Components]]\nType = \"Fake\"\nData = [nan]\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 9"))
+	})
+}
+
+func TestTomlTemplateRejectsNull(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		template := &c.ObjectTemplate{
+			Name:       "One",
+			Components: []c.ComponentTemplate{{Type: "Fake", Data: map[string]interface{}{"Speed": nil}}}}
+		_, err := c.ObjectTemplateAsToml(template)
+		T.Assert(errors.Is(err, c.ErrNotSupported{}))
+
+		template.Components[0].Data = map[string]interface{}{"Speeds": []interface{}{1.0, nil}}
+		_, err = c.ObjectTemplateAsToml(template)
+		T.Assert(errors.Is(err, c.ErrNotSupported{}))
+
+		template.Components[0].Data = map[string]interface{}{"Speed": map[string]interface{}{"Max": nil}}
+		_, err = c.ObjectTemplateAsToml(template)
+		T.Assert(errors.Is(err, c.ErrNotSupported{}))
+
+		template.Components[0].Data = nil
+		_, err = c.ObjectTemplateAsToml(template)
+		T.Assert(err == nil)
+	})
+}
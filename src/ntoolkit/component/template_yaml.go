@@ -0,0 +1,982 @@
+package component
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ObjectTemplateFromYaml loads an object template from a yaml block.
+// The supported yaml is the subset useful for hand written templates: block and flow
+// mappings and sequences, plain and quoted scalars, literal and folded block scalars
+// and comments. Anchors, aliases, tags and multiple documents are rejected.
+func ObjectTemplateFromYaml(raw string) (*ObjectTemplate, error) {
+	parser := newYamlParser(raw)
+	node, err := parser.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	decoder := &templateDecoder{format: "yaml"}
+	return decoder.objectTemplate(node)
+}
+
+// ObjectTemplateAsYaml saves an object template as a yaml block.
+func ObjectTemplateAsYaml(template *ObjectTemplate) ([]byte, error) {
+	writer := &yamlWriter{}
+	if err := writer.writeObject(template, 0); err != nil {
+		return nil, err
+	}
+	return []byte(writer.output.String()), nil
+}
+
+// yamlParser is a recursive descent parser for the yaml subset used by templates.
+type yamlParser struct {
+	src    []rune
+	pos    int
+	line   int
+	column int
+}
+
+var yamlIntPattern = regexp.MustCompile(`^[-+]?[0-9]+$`)
+var yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// newYamlParser returns a parser for the given document
+func newYamlParser(raw string) *yamlParser {
+	raw = strings.Replace(raw, "\r\n", "\n", -1)
+	return &yamlParser{src: []rune(raw), line: 1, column: 1}
+}
+
+// fail returns an error at the current position
+func (p *yamlParser) fail(message string) error {
+	return templateSyntaxError("yaml", p.line, p.column, message)
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// peek returns the rune at the given offset from the cursor, or 0 past the end
+func (p *yamlParser) peek(offset ...int) rune {
+	i := p.pos
+	if len(offset) > 0 {
+		i += offset[0]
+	}
+	if i >= len(p.src) {
+		return 0
+	}
+	return p.src[i]
+}
+
+// next consumes a single rune
+func (p *yamlParser) next() rune {
+	r := p.src[p.pos]
+	p.pos += 1
+	if r == '\n' {
+		p.line += 1
+		p.column = 1
+	} else {
+		p.column += 1
+	}
+	return r
+}
+
+// indent returns the indentation of the current position
+func (p *yamlParser) indent() int {
+	return p.column - 1
+}
+
+// isBlank checks if a rune ends a token in block context
+func isYamlBlank(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == 0
+}
+
+// isFlowIndicator checks if a rune is a flow collection indicator
+func isYamlFlowIndicator(r rune) bool {
+	return r == ',' || r == '[' || r == ']' || r == '{' || r == '}'
+}
+
+// atLineStart checks if only whitespace precedes the cursor on this line
+func (p *yamlParser) atLineStart() bool {
+	for i := p.pos - 1; i >= 0 && p.src[i] != '\n'; i-- {
+		if p.src[i] != ' ' && p.src[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequenceEntry checks if the cursor is at a block sequence entry
+func (p *yamlParser) isSequenceEntry() bool {
+	return p.peek() == '-' && isYamlBlank(p.peek(1))
+}
+
+// isDocumentMarker checks if the cursor is at a '---' or '...' marker
+func (p *yamlParser) isDocumentMarker(marker string) bool {
+	if p.column != 1 {
+		return false
+	}
+	for i, r := range marker {
+		if p.peek(i) != r {
+			return false
+		}
+	}
+	return isYamlBlank(p.peek(len(marker)))
+}
+
+// skipInline skips spaces and a trailing comment on the current line
+func (p *yamlParser) skipInline() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.next()
+		}
+	}
+}
+
+// skipSpace skips whitespace, comments and blank lines up to the next token
+func (p *yamlParser) skipSpace() error {
+	tabLine := 0
+	tabColumn := 0
+	for !p.eof() {
+		switch p.peek() {
+		case ' ':
+			p.next()
+		case '\t':
+			if p.atLineStart() && tabLine == 0 {
+				tabLine = p.line
+				tabColumn = p.column
+			}
+			p.next()
+		case '\n':
+			tabLine = 0
+			p.next()
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		default:
+			if tabLine == p.line && p.atLineStart() {
+				return templateSyntaxError("yaml", tabLine, tabColumn, "Tabs are not allowed for indentation")
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// endLine checks that nothing but a comment follows the last token on the line
+func (p *yamlParser) endLine() error {
+	p.skipInline()
+	if !p.eof() && p.peek() != '\n' && !p.atLineStart() {
+		return p.fail(fmt.Sprintf("Unexpected '%c'", p.peek()))
+	}
+	return nil
+}
+
+// parseDocument parses the entire input as a single document
+func (p *yamlParser) parseDocument() (*templateNode, error) {
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	if p.peek() == '%' {
+		return nil, p.fail("Directives are not supported")
+	}
+	if p.isDocumentMarker("---") {
+		p.next()
+		p.next()
+		p.next()
+		p.skipInline()
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+
+	var node *templateNode
+	if !p.eof() && !p.isDocumentMarker("...") {
+		var err error
+		if node, err = p.parseBlockNode(-1); err != nil {
+			return nil, err
+		}
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.isDocumentMarker("...") {
+		p.next()
+		p.next()
+		p.next()
+		p.skipInline()
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.eof() {
+		if p.isDocumentMarker("---") {
+			return nil, p.fail("Multiple documents are not supported")
+		}
+		return nil, p.fail(fmt.Sprintf("Unexpected '%c'", p.peek()))
+	}
+	if node == nil {
+		return nil, p.fail("Template is empty")
+	}
+	return node, nil
+}
+
+// parseBlockNode parses the block node starting at the cursor; the node's indentation is the cursor column.
+// parentIndent is the indentation of the enclosing collection, or -1 at the top level.
+func (p *yamlParser) parseBlockNode(parentIndent int) (*templateNode, error) {
+	indent := p.indent()
+	switch r := p.peek(); {
+	case p.isSequenceEntry():
+		return p.parseSequence(indent)
+	case r == '|' || r == '>':
+		return p.parseBlockScalar(parentIndent)
+	case r == '[' || r == '{':
+		node, err := p.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+		p.skipInline()
+		if p.peek() == ':' {
+			return nil, p.fail("Complex mapping keys are not supported")
+		}
+		return node, nil
+	}
+
+	node, err := p.parseScalar(false)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+	if p.peek() == ':' && isYamlBlank(p.peek(1)) {
+		return p.parseMapping(indent, node)
+	}
+	return node, nil
+}
+
+// parseInlineNode parses a mapping value that starts on the same line as its key
+func (p *yamlParser) parseInlineNode(parentIndent int) (*templateNode, error) {
+	switch p.peek() {
+	case '|', '>':
+		return p.parseBlockScalar(parentIndent)
+	case '[', '{':
+		return p.parseFlowNode()
+	}
+	if p.isSequenceEntry() {
+		return nil, p.fail("Block sequences are not allowed here")
+	}
+	node, err := p.parseScalar(false)
+	if err != nil {
+		return nil, err
+	}
+	p.skipInline()
+	if p.peek() == ':' {
+		return nil, p.fail("Mapping values are not allowed here")
+	}
+	return node, nil
+}
+
+// parseMapping parses a block mapping at the given indentation, starting at the ':' after the first key
+func (p *yamlParser) parseMapping(indent int, key *templateNode) (*templateNode, error) {
+	rtn := newTemplateMapping(key.line, key.column)
+	for {
+		if err := p.addKey(rtn, key); err != nil {
+			return nil, err
+		}
+		p.next() // ':'
+
+		line, column := p.line, p.column
+		value, err := p.parseMappingValue(indent)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			value = newTemplateScalar(nil, "", line, column)
+		}
+		rtn.values = append(rtn.values, value)
+
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.indent() < indent || p.isDocumentMarker("---") || p.isDocumentMarker("...") {
+			break
+		}
+		if p.indent() > indent {
+			return nil, p.fail("Unexpected indentation")
+		}
+		if p.isSequenceEntry() {
+			return nil, p.fail("Unexpected sequence entry in a mapping")
+		}
+
+		if key, err = p.parseKey(); err != nil {
+			return nil, err
+		}
+	}
+	return rtn, nil
+}
+
+// parseKey parses a block mapping key and leaves the cursor on the following ':'
+func (p *yamlParser) parseKey() (*templateNode, error) {
+	if p.peek() == '[' || p.peek() == '{' || p.peek() == '?' {
+		return nil, p.fail("Complex mapping keys are not supported")
+	}
+	key, err := p.parseScalar(false)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+	if p.peek() != ':' || !isYamlBlank(p.peek(1)) {
+		return nil, p.fail("Expected ':' after mapping key")
+	}
+	return key, nil
+}
+
+// addKey adds a key to a mapping; the key is always stored as a string.
+func (p *yamlParser) addKey(mapping *templateNode, key *templateNode) error {
+	if key.kind != templateScalar {
+		return templateSyntaxError("yaml", key.line, key.column, "Mapping keys must be scalars")
+	}
+	name, ok := key.value.(string)
+	if !ok {
+		name = key.raw
+	}
+	if mapping.get(name) != nil {
+		return templateSyntaxError("yaml", key.line, key.column, fmt.Sprintf("Duplicate mapping key '%s'", name))
+	}
+	mapping.keys = append(mapping.keys, newTemplateScalar(name, key.raw, key.line, key.column))
+	return nil
+}
+
+// parseMappingValue parses the value after a mapping key, or returns nil for an empty value
+func (p *yamlParser) parseMappingValue(indent int) (*templateNode, error) {
+	p.skipInline()
+	if !p.eof() && p.peek() != '\n' {
+		return p.parseInlineNode(indent)
+	}
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	if p.eof() || p.isDocumentMarker("---") || p.isDocumentMarker("...") {
+		return nil, nil
+	}
+	if p.indent() > indent {
+		return p.parseBlockNode(indent)
+	}
+	if p.indent() == indent && p.isSequenceEntry() {
+		return p.parseSequence(indent)
+	}
+	return nil, nil
+}
+
+// parseSequence parses a block sequence at the given indentation, starting at the first '-'
+func (p *yamlParser) parseSequence(indent int) (*templateNode, error) {
+	rtn := newTemplateSequence(p.line, p.column)
+	for {
+		p.next() // '-'
+
+		line, column := p.line, p.column
+		item, err := p.parseSequenceItem(indent)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			item = newTemplateScalar(nil, "", line, column)
+		}
+		rtn.values = append(rtn.values, item)
+
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.indent() < indent || p.isDocumentMarker("---") || p.isDocumentMarker("...") {
+			break
+		}
+		if p.indent() > indent {
+			return nil, p.fail("Unexpected indentation")
+		}
+		if !p.isSequenceEntry() {
+			break
+		}
+	}
+	return rtn, nil
+}
+
+// parseSequenceItem parses the value after a '-', or returns nil for an empty item
+func (p *yamlParser) parseSequenceItem(indent int) (*templateNode, error) {
+	p.skipInline()
+	if !p.eof() && p.peek() != '\n' {
+		return p.parseBlockNode(indent)
+	}
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	if p.eof() || p.indent() <= indent || p.isDocumentMarker("---") || p.isDocumentMarker("...") {
+		return nil, nil
+	}
+	return p.parseBlockNode(indent)
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar
+func (p *yamlParser) parseBlockScalar(parentIndent int) (*templateNode, error) {
+	line, column := p.line, p.column
+	folded := p.next() == '>'
+	chomp := byte(0)
+	explicit := 0
+	for i := 0; i < 2; i++ {
+		r := p.peek()
+		if (r == '-' || r == '+') && chomp == 0 {
+			chomp = byte(r)
+			p.next()
+		} else if r >= '1' && r <= '9' && explicit == 0 {
+			explicit = int(r - '0')
+			p.next()
+		}
+	}
+	if !isYamlBlank(p.peek()) && p.peek() != '#' {
+		return nil, p.fail("Invalid block scalar header")
+	}
+	p.skipInline()
+	if !p.eof() {
+		if p.peek() != '\n' {
+			return nil, p.fail("Invalid block scalar header")
+		}
+		p.next()
+	}
+
+	// Collect the raw lines that belong to the scalar
+	contentIndent := -1
+	if explicit > 0 {
+		contentIndent = parentIndent + explicit
+		if parentIndent < 0 {
+			contentIndent = explicit
+		}
+	}
+	lines := make([]string, 0)
+	for !p.eof() {
+		start := p.pos
+		spaces := 0
+		for start+spaces < len(p.src) && p.src[start+spaces] == ' ' {
+			spaces += 1
+		}
+		end := start + spaces
+		for end < len(p.src) && p.src[end] != '\n' {
+			end += 1
+		}
+		text := string(p.src[start+spaces : end])
+		if strings.TrimSpace(text) == "" {
+			lines = append(lines, "")
+		} else {
+			if contentIndent < 0 {
+				if spaces <= parentIndent {
+					break
+				}
+				contentIndent = spaces
+			}
+			if spaces < contentIndent {
+				break
+			}
+			if p.column == 1 && (p.isDocumentMarker("---") || p.isDocumentMarker("...")) {
+				break
+			}
+			lines = append(lines, strings.Repeat(" ", spaces-contentIndent)+text)
+		}
+		for p.pos < end {
+			p.next()
+		}
+		if !p.eof() {
+			p.next()
+		}
+	}
+
+	// Trailing blank lines are only kept with the + indicator
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing += 1
+	}
+	body := lines[:len(lines)-trailing]
+	value := ""
+	if folded {
+		for i := 0; i < len(body); i++ {
+			if i > 0 {
+				if body[i] == "" || body[i-1] == "" || strings.HasPrefix(body[i], " ") || strings.HasPrefix(body[i-1], " ") {
+					value += "\n"
+				} else {
+					value += " "
+				}
+			}
+			value += body[i]
+		}
+	} else {
+		value = strings.Join(body, "\n")
+	}
+	if len(body) > 0 && chomp != '-' {
+		value += "\n"
+	}
+	if chomp == '+' {
+		value += strings.Repeat("\n", trailing)
+	}
+
+	// Leave the cursor on the first line that is not part of the scalar
+	return newTemplateScalar(value, value, line, column), nil
+}
+
+// parseScalar parses a quoted or plain scalar at the cursor
+func (p *yamlParser) parseScalar(flow bool) (*templateNode, error) {
+	line, column := p.line, p.column
+	switch r := p.peek(); r {
+	case '"':
+		value, err := p.parseDoubleQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case '\'':
+		value, err := p.parseSingleQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return newTemplateScalar(value, value, line, column), nil
+	case '&', '*':
+		return nil, p.fail("Anchors and aliases are not supported")
+	case '!':
+		return nil, p.fail("Tags are not supported")
+	case '?':
+		if isYamlBlank(p.peek(1)) {
+			return nil, p.fail("Complex mapping keys are not supported")
+		}
+	case '@', '`', '%':
+		return nil, p.fail(fmt.Sprintf("Plain scalars cannot start with '%c'", r))
+	case ']', '}', ',':
+		return nil, p.fail(fmt.Sprintf("Unexpected '%c'", r))
+	}
+
+	start := p.pos
+	end := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if r == '\n' {
+			break
+		}
+		if r == ':' && (isYamlBlank(p.peek(1)) || (flow && isYamlFlowIndicator(p.peek(1)))) {
+			break
+		}
+		if r == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		if flow && isYamlFlowIndicator(r) {
+			break
+		}
+		p.next()
+		if r != ' ' && r != '\t' {
+			end = p.pos
+		}
+	}
+	raw := string(p.src[start:end])
+	value := resolveYamlScalar(raw)
+	if number, ok := value.(float64); ok && (math.IsInf(number, 0) || math.IsNaN(number)) {
+		return nil, templateSyntaxError("yaml", line, column, "Infinite and NaN numbers are not supported; component data must be valid json")
+	}
+	return newTemplateScalar(value, raw, line, column), nil
+}
+
+// resolveYamlScalar resolves a plain scalar using the yaml core schema; numbers are float64 like encoding/json.
+func resolveYamlScalar(raw string) interface{} {
+	switch raw {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if strings.HasPrefix(raw, "0x") || strings.HasPrefix(raw, "0o") {
+		base := 16
+		if raw[1] == 'o' {
+			base = 8
+		}
+		if value, err := strconv.ParseUint(raw[2:], base, 64); err == nil {
+			return float64(value)
+		}
+		return raw
+	}
+	if yamlIntPattern.MatchString(raw) || yamlFloatPattern.MatchString(raw) {
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+// parseDoubleQuoted parses a double quoted scalar, including escapes and line folding
+func (p *yamlParser) parseDoubleQuoted() (string, error) {
+	p.next() // '"'
+	rtn := &strings.Builder{}
+	for {
+		if p.eof() {
+			return "", p.fail("Unterminated double quoted string")
+		}
+		r := p.peek()
+		switch {
+		case r == '"':
+			p.next()
+			return rtn.String(), nil
+		case r == '\\' && p.peek(1) == '\n':
+			p.next()
+			p.next()
+			for p.peek() == ' ' || p.peek() == '\t' {
+				p.next()
+			}
+		case r == '\\':
+			line, column := p.line, p.column
+			p.next()
+			if p.eof() {
+				return "", p.fail("Unterminated double quoted string")
+			}
+			if err := p.parseEscape(rtn); err != nil {
+				return "", templateSyntaxError("yaml", line, column, err.Error())
+			}
+		case r == '\n' || r == ' ' || r == '\t':
+			p.foldQuoted(rtn)
+		default:
+			rtn.WriteRune(p.next())
+		}
+	}
+}
+
+// parseEscape decodes a single escape sequence after a '\'
+func (p *yamlParser) parseEscape(output *strings.Builder) error {
+	simple := map[rune]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+		'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085",
+		'_': " ", 'L': " ", 'P': " "}
+	r := p.next()
+	if value, ok := simple[r]; ok {
+		output.WriteString(value)
+		return nil
+	}
+	size := 0
+	switch r {
+	case 'x':
+		size = 2
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return fmt.Errorf("Invalid escape sequence '\\%c'", r)
+	}
+	digits := ""
+	for i := 0; i < size && !p.eof(); i++ {
+		digits += string(p.next())
+	}
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != size || !utf8.ValidRune(rune(value)) {
+		return fmt.Errorf("Invalid escape sequence '\\%c%s'", r, digits)
+	}
+	output.WriteRune(rune(value))
+	return nil
+}
+
+// parseSingleQuoted parses a single quoted scalar, where a doubled quote is an escaped quote
+func (p *yamlParser) parseSingleQuoted() (string, error) {
+	p.next() // '\''
+	rtn := &strings.Builder{}
+	for {
+		if p.eof() {
+			return "", p.fail("Unterminated single quoted string")
+		}
+		r := p.peek()
+		switch {
+		case r == '\'' && p.peek(1) == '\'':
+			p.next()
+			p.next()
+			rtn.WriteRune('\'')
+		case r == '\'':
+			p.next()
+			return rtn.String(), nil
+		case r == '\n' || r == ' ' || r == '\t':
+			p.foldQuoted(rtn)
+		default:
+			rtn.WriteRune(p.next())
+		}
+	}
+}
+
+// foldQuoted handles whitespace in a quoted scalar; a single line break folds into
+// a space, while each additional empty line becomes a line feed.
+func (p *yamlParser) foldQuoted(output *strings.Builder) {
+	whitespace := ""
+	for p.peek() == ' ' || p.peek() == '\t' {
+		whitespace += string(p.next())
+	}
+	if p.peek() != '\n' {
+		output.WriteString(whitespace)
+		return
+	}
+	breaks := 0
+	for p.peek() == '\n' || p.peek() == ' ' || p.peek() == '\t' {
+		if p.next() == '\n' {
+			breaks += 1
+		}
+	}
+	if breaks == 1 {
+		output.WriteRune(' ')
+	} else {
+		output.WriteString(strings.Repeat("\n", breaks-1))
+	}
+}
+
+// skipFlowSpace skips whitespace, line breaks and comments inside a flow collection
+func (p *yamlParser) skipFlowSpace() {
+	for !p.eof() {
+		r := p.peek()
+		if r == ' ' || r == '\t' || r == '\n' {
+			p.next()
+		} else if r == '#' {
+			p.skipInline()
+		} else {
+			break
+		}
+	}
+}
+
+// parseFlowNode parses a flow collection or a scalar inside a flow collection
+func (p *yamlParser) parseFlowNode() (*templateNode, error) {
+	p.skipFlowSpace()
+	if p.eof() {
+		return nil, p.fail("Unterminated flow collection")
+	}
+	line, column := p.line, p.column
+	switch p.peek() {
+	case '[':
+		p.next()
+		rtn := newTemplateSequence(line, column)
+		for {
+			p.skipFlowSpace()
+			if p.peek() == ']' {
+				p.next()
+				return rtn, nil
+			}
+			item, err := p.parseFlowNode()
+			if err != nil {
+				return nil, err
+			}
+			rtn.values = append(rtn.values, item)
+			p.skipFlowSpace()
+			if p.peek() == ':' {
+				return nil, p.fail("Mappings inside flow sequences are not supported")
+			}
+			if err := p.endFlowItem(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		p.next()
+		rtn := newTemplateMapping(line, column)
+		for {
+			p.skipFlowSpace()
+			if p.peek() == '}' {
+				p.next()
+				return rtn, nil
+			}
+			if p.peek() == '[' || p.peek() == '{' {
+				return nil, p.fail("Complex mapping keys are not supported")
+			}
+			key, err := p.parseScalar(true)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.addKey(rtn, key); err != nil {
+				return nil, err
+			}
+			p.skipFlowSpace()
+			var value *templateNode
+			if p.peek() == ':' {
+				p.next()
+				p.skipFlowSpace()
+				if p.peek() != ',' && p.peek() != '}' {
+					if value, err = p.parseFlowNode(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if value == nil {
+				value = newTemplateScalar(nil, "", p.line, p.column)
+			}
+			rtn.values = append(rtn.values, value)
+			p.skipFlowSpace()
+			if err := p.endFlowItem('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.parseScalar(true)
+}
+
+// endFlowItem consumes the ',' after a flow item, or leaves the closing bracket for the caller
+func (p *yamlParser) endFlowItem(closing rune) error {
+	if p.eof() {
+		return p.fail("Unterminated flow collection")
+	}
+	if p.peek() == ',' {
+		p.next()
+		return nil
+	}
+	if p.peek() != closing {
+		return p.fail(fmt.Sprintf("Expected ',' or '%c'", closing))
+	}
+	return nil
+}
+
+// yamlWriter renders object templates as block yaml
+type yamlWriter struct {
+	output strings.Builder
+}
+
+// writeObject writes an object template as a mapping; the first line is written without indentation
+// so that it can follow a sequence entry marker.
+func (writer *yamlWriter) writeObject(template *ObjectTemplate, indent int) error {
+	writer.output.WriteString(fmt.Sprintf("Name: %s\n", yamlScalar(template.Name)))
+	prefix := strings.Repeat(" ", indent)
+	if template.ID != "" {
+		writer.output.WriteString(fmt.Sprintf("%sID: %s\n", prefix, yamlScalar(template.ID)))
+	}
+	if len(template.Tags) > 0 {
+		tags := make([]string, len(template.Tags))
+		for i := 0; i < len(template.Tags); i++ {
+			tags[i] = yamlScalar(template.Tags[i])
+		}
+		writer.output.WriteString(fmt.Sprintf("%sTags: [%s]\n", prefix, strings.Join(tags, ", ")))
+	}
+	if template.Inactive {
+		writer.output.WriteString(prefix + "Inactive: true\n")
+	}
+	if template.Components != nil {
+		writer.output.WriteString(prefix + "Components:")
+		if len(template.Components) == 0 {
+			writer.output.WriteString(" []\n")
+		} else {
+			writer.output.WriteString("\n")
+		}
+		for i := 0; i < len(template.Components); i++ {
+			writer.output.WriteString(fmt.Sprintf("%s  - Type: %s\n", prefix, yamlScalar(template.Components[i].Type)))
+			if template.Components[i].Disabled {
+				writer.output.WriteString(prefix + "    Disabled: true\n")
+			}
+			data, err := plainTemplateData(template.Components[i].Data)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				writer.output.WriteString(prefix + "    Data:")
+				writer.writeValue(data, indent+6)
+			}
+		}
+	}
+	if template.Objects != nil {
+		writer.output.WriteString(prefix + "Objects:")
+		if len(template.Objects) == 0 {
+			writer.output.WriteString(" []\n")
+		} else {
+			writer.output.WriteString("\n")
+		}
+		for i := 0; i < len(template.Objects); i++ {
+			writer.output.WriteString(prefix + "  - ")
+			if err := writer.writeObject(&template.Objects[i], indent+4); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeValue writes a plain value after a 'key:' or '-' marker that has already been written
+func (writer *yamlWriter) writeValue(value interface{}, indent int) {
+	prefix := strings.Repeat(" ", indent)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			writer.output.WriteString(" {}\n")
+			return
+		}
+		writer.output.WriteString("\n")
+		writer.writeMapping(v, indent, prefix)
+	case []interface{}:
+		if len(v) == 0 {
+			writer.output.WriteString(" []\n")
+			return
+		}
+		writer.output.WriteString("\n")
+		for _, item := range v {
+			if mapping, ok := item.(map[string]interface{}); ok && len(mapping) > 0 {
+				writer.output.WriteString(prefix + "- ")
+				writer.writeMapping(mapping, indent+2, "")
+			} else {
+				writer.output.WriteString(prefix + "-")
+				writer.writeValue(item, indent+2)
+			}
+		}
+	default:
+		writer.output.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// writeMapping writes the keys of a mapping at the given indentation; the first key is
+// written with firstPrefix so that it can follow a sequence entry marker.
+func (writer *yamlWriter) writeMapping(mapping map[string]interface{}, indent int, firstPrefix string) {
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	prefix := firstPrefix
+	for _, key := range keys {
+		writer.output.WriteString(prefix + yamlScalar(key) + ":")
+		writer.writeValue(mapping[key], indent+2)
+		prefix = strings.Repeat(" ", indent)
+	}
+}
+
+// yamlScalar renders a plain scalar value, quoting strings that would not read back as themselves
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		if v == "" || v != strings.TrimSpace(v) || resolveYamlScalar(v) != v ||
+			strings.ContainsAny(v, "\n\t\"'#:,[]{}") || strings.ContainsAny(v[:1], "-?&*!|>%@`") {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	return strconv.Quote(fmt.Sprintf("%v", value))
+}
@@ -0,0 +1,101 @@
+package component_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	c "ntoolkit/component"
+	"ntoolkit/errors"
+)
+
+func TestYamlTemplateMatchesJson(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		fromJson, err := c.ObjectTemplateFromJson(objectTemplateSimple)
+		T.Assert(err == nil)
+
+		fromYaml, err := c.ObjectTemplateFromYaml(objectTemplateSimpleYaml)
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(fromJson, fromYaml))
+
+		fromJson, err = c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+
+		fromYaml, err = c.ObjectTemplateFromYaml(objectTemplateNestedYaml)
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(fromJson, fromYaml))
+	})
+}
+
+func TestJsonTemplateIsValidYaml(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		fromJson, err := c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+
+		fromYaml, err := c.ObjectTemplateFromYaml(objectTemplateNested)
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(fromJson, fromYaml))
+	})
+}
+
+func TestYamlTemplateToObjectViaFactory(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := c.NewObjectFactory()
+		factory.Register(&FakeComponent{})
+		factory.Register(&FakeConfiguredComponent{})
+
+		template, err := c.ObjectTemplateFromYaml(objectTemplateNestedYaml)
+		T.Assert(err == nil)
+
+		instance, err := factory.Deserialize(template)
+		T.Assert(err == nil)
+
+		var cmp *FakeConfiguredComponent
+		err = instance.Find(&cmp, "One", "Two")
+		T.Assert(err == nil)
+		T.Assert(len(cmp.Data.Items) == 3)
+		T.Assert(cmp.Data.Items[2].Id == "3")
+		T.Assert(cmp.Data.Items[2].Count == 3)
+	})
+}
+
+func TestYamlTemplateRoundTrip(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		template, err := c.ObjectTemplateFromJson(objectTemplateNested)
+		T.Assert(err == nil)
+		template.Name = "Name: with 'quotes' and \"escapes\"\n"
+		template.Components[0].Data = "Value,1"
+
+		raw, err := c.ObjectTemplateAsYaml(template)
+		T.Assert(err == nil)
+
+		copy, err := c.ObjectTemplateFromYaml(string(raw))
+		T.Assert(err == nil)
+		T.Assert(reflect.DeepEqual(template, copy))
+	})
+}
+
+func TestYamlTemplateErrorPosition(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, err := c.ObjectTemplateFromYaml("Name: One\nComponents:\n  - Type: [1, 2\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 1"))
+
+		_, err = c.ObjectTemplateFromYaml("Name: One\nObjects:\n  - Name: Two\n     Bad: Indent\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 6"))
+
+		_, err = c.ObjectTemplateFromYaml("Name: One\nObjects:\n  - Name: [Two]\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 3, column 11"))
+
+		_, err = c.ObjectTemplateFromYaml("Name: One\nComponents:\n  - Type: Fake\n    Data: {Speed: .inf}\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 4, column 19"))
+
+		_, err = c.ObjectTemplateFromYaml("Name: One\nComponents:\n  - Type: Fake\n    Data:\n      - .nan\n")
+		T.Assert(errors.Is(err, c.ErrBadValue{}))
+		T.Assert(strings.Contains(err.Error(), "line 5, column 9"))
+	})
+}
@@ -0,0 +1,327 @@
+package component
+
+import (
+	"bytes"
+	"fmt"
+	goruntime "runtime"
+	"sync"
+	"time"
+
+	"ntoolkit/errors"
+)
+
+// ComponentHang is a component Start or Update that ran past the watchdog deadline; see
+// Config.Watchdog.
+type ComponentHang struct {
+	Object    *Object
+	Component Component
+	Phase     string        // "Start" or "Update"
+	Elapsed   time.Duration // How long the component had been running when it was found
+	Stack     string        // The stack of the goroutine running the component
+	Faulted   bool          // The component was faulted, and is no longer started or updated
+}
+
+// The fault states of a component
+const (
+	notFaulted      int32 = iota // The component is started and updated as normal
+	faultedRunning               // The component is faulted, and its hung call has not returned
+	faultedReturned              // The component is faulted, and its hung call has returned
+)
+
+// watchdog checks for components that take longer than the deadline to start or update, while the
+// objects of a frame are updated
+type watchdog struct {
+	runtime  *Runtime
+	deadline time.Duration
+	fault    bool
+	watches  map[*watch]bool // The watch of every worker job in progress
+	hangs    []ComponentHang
+	lock     *sync.Mutex
+}
+
+// watch is the component a worker job is running, for the watchdog to check
+type watch struct {
+	goroutine uint64
+	object    *Object
+	info      *componentInfo
+	phase     string
+	started   time.Time
+	reported  bool
+	index     int            // The index in the job of the object being updated
+	hung      *componentInfo // The component the job was abandoned in, if any
+	abandoned chan struct{}  // Closed when the component is faulted; the job carries on without it
+	lock      *sync.Mutex
+}
+
+func newWatchdog(runtime *Runtime, deadline time.Duration, fault bool) *watchdog {
+	return &watchdog{
+		runtime:  runtime,
+		deadline: deadline,
+		fault:    fault,
+		watches:  make(map[*watch]bool),
+		lock:     &sync.Mutex{}}
+}
+
+func newWatch() *watch {
+	return &watch{abandoned: make(chan struct{}), lock: &sync.Mutex{}}
+}
+
+// next records that the job is updating the object at index, and returns false if the job has been
+// abandoned
+func (w *watch) next(index int) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.index = index
+	return !w.isAbandoned()
+}
+
+// enter records that the job is running a component, and returns false if the job has been abandoned
+func (w *watch) enter(object *Object, info *componentInfo) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.isAbandoned() {
+		return false
+	}
+	w.object = object
+	w.info = info
+	w.phase = "Update"
+	if info.Active == 0 && info.Start != nil {
+		w.phase = "Start"
+	}
+	w.started = time.Now()
+	w.reported = false
+	return true
+}
+
+// leave records that the job has finished running a component, and runs record unless the job has
+// been abandoned
+func (w *watch) leave(record func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.isAbandoned() {
+		if w.info != nil {
+			w.info.faulted.CompareAndSwap(faultedRunning, faultedReturned)
+		}
+	} else {
+		record()
+	}
+	w.object = nil
+	w.info = nil
+}
+
+// record runs record unless the job has been abandoned; the stats of a job are only changed through
+// its watch, so they can be merged when it is abandoned
+func (w *watch) record(record func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.isAbandoned() {
+		record()
+	}
+}
+
+// isAbandoned checks if the job has been abandoned; the lock must be held
+func (w *watch) isAbandoned() bool {
+	select {
+	case <-w.abandoned:
+		return true
+	default:
+		return false
+	}
+}
+
+// start checks the jobs in progress until the returned function is called
+func (dog *watchdog) start() func() {
+	stop := make(chan struct{})
+	interval := dog.deadline / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	go (func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				dog.check(now)
+			}
+		}
+	})()
+	return func() {
+		close(stop)
+	}
+}
+
+// check reports every component that has been running for longer than the deadline, and faults it if
+// the watchdog is configured to
+func (dog *watchdog) check(now time.Time) {
+	dog.lock.Lock()
+	watches := make([]*watch, 0, len(dog.watches))
+	for w := range dog.watches {
+		watches = append(watches, w)
+	}
+	dog.lock.Unlock()
+	for _, w := range watches {
+		if hang, info := dog.checkWatch(w, now); info != nil {
+			dog.lock.Lock()
+			dog.hangs = append(dog.hangs, hang)
+			dog.lock.Unlock()
+			info.componentLogger(hang.Object, dog.runtime).Error("Component exceeded the watchdog deadline",
+				"phase", hang.Phase,
+				"elapsed", hang.Elapsed,
+				"deadline", dog.deadline,
+				"faulted", hang.Faulted,
+				"stack", hang.Stack)
+		}
+	}
+}
+
+// checkWatch returns the hang of a job, and the component that hung, if it is running a component
+// that has not been reported and has passed the deadline
+func (dog *watchdog) checkWatch(w *watch, now time.Time) (ComponentHang, *componentInfo) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.info == nil || w.reported || now.Sub(w.started) < dog.deadline {
+		return ComponentHang{}, nil
+	}
+	w.reported = true
+	hang := ComponentHang{
+		Object:    w.object,
+		Component: w.info.Component,
+		Phase:     w.phase,
+		Elapsed:   now.Sub(w.started),
+		Stack:     goroutineStack(w.goroutine),
+		Faulted:   dog.fault}
+	if dog.fault {
+		w.info.faulted.Store(faultedRunning)
+		w.hung = w.info
+		close(w.abandoned)
+	}
+	return hang, w.info
+}
+
+// updateChunk updates a set of objects in a worker job while the watchdog watches it. If the
+// watchdog faults a component, the job stops waiting for it and carries on with the rest of the
+// components of the same object, and then the next objects, on a new goroutine; the stats collected
+// before the fault are kept. The hung goroutine stops as soon as the component returns, but until
+// then it may still change its object.
+func (dog *watchdog) updateChunk(step float32, scales []float32, objects []*Object, collector *frameCollector) {
+	runtime := dog.runtime
+	var resume *componentInfo
+	for start := 0; start < len(objects); {
+		stats := collector.worker()
+		if stats == nil {
+			stats = newUpdateStats(false, 0)
+		}
+		stats.watch = newWatch()
+		done := make(chan struct{})
+		job := func(from int, after *componentInfo) {
+			dog.add(stats.watch)
+			for i := from; i < len(objects) && stats.watch.next(i); i++ {
+				if objects[i].runtime == nil {
+					objects[i].runtime = runtime
+				}
+				if i == from && after != nil {
+					objects[i].resume(step, scales[i], runtime, stats, after)
+				} else {
+					objects[i].update(step, scales[i], runtime, stats)
+				}
+			}
+			dog.remove(stats.watch)
+			close(done)
+		}
+		if dog.fault {
+			go job(start, resume)
+		} else {
+			job(start, resume)
+		}
+		select {
+		case <-done:
+			collector.merge(stats)
+			return
+		case <-stats.watch.abandoned:
+			dog.remove(stats.watch)
+			stats.watch.lock.Lock()
+			collector.merge(stats)
+			start = stats.watch.index
+			resume = stats.watch.hung
+			stats.watch.lock.Unlock()
+		}
+	}
+}
+
+// add starts watching a job running on the calling goroutine
+func (dog *watchdog) add(w *watch) {
+	w.lock.Lock()
+	w.goroutine = goroutineID()
+	w.lock.Unlock()
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	dog.watches[w] = true
+}
+
+// remove stops watching a job
+func (dog *watchdog) remove(w *watch) {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	delete(dog.watches, w)
+}
+
+// goroutineStack returns the stack trace of a goroutine, or an empty string if it is not found
+func goroutineStack(id uint64) string {
+	buffer := make([]byte, 64*1024)
+	for {
+		size := goruntime.Stack(buffer, true)
+		if size < len(buffer) || len(buffer) >= 64*1024*1024 {
+			buffer = buffer[:size]
+			break
+		}
+		buffer = make([]byte, len(buffer)*2)
+	}
+	prefix := []byte(fmt.Sprintf("goroutine %d ", id))
+	for _, stack := range bytes.Split(buffer, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, prefix) {
+			return string(stack)
+		}
+	}
+	return ""
+}
+
+// Hangs returns the component starts and updates the watchdog has found, if the runtime was
+// configured with a Watchdog.
+func (runtime *Runtime) Hangs() []ComponentHang {
+	if runtime.watchdog == nil {
+		return nil
+	}
+	runtime.watchdog.lock.Lock()
+	defer runtime.watchdog.lock.Unlock()
+	return append([]ComponentHang(nil), runtime.watchdog.hangs...)
+}
+
+// Faulted checks if the watchdog has faulted a component on the object, so it is no longer started or
+// updated; see Config.FaultHung.
+func (o *Object) Faulted(component Component) bool {
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if components[i].Component == component {
+			return components[i].faulted.Load() != notFaulted
+		}
+	}
+	return false
+}
+
+// ClearFault lets a component that the watchdog has faulted be started or updated again. It fails
+// with an ErrBadValue while the call that hung has not returned.
+func (o *Object) ClearFault(component Component) error {
+	components := o.components
+	for i := 0; i < len(components); i++ {
+		if components[i].Component == component {
+			if components[i].faulted.CompareAndSwap(faultedReturned, notFaulted) || components[i].faulted.Load() == notFaulted {
+				return nil
+			}
+			return errors.Fail(ErrBadValue{}, nil, fmt.Sprintf("Cannot clear the fault of component %s on object '%s'; it is still running", typeName(component.Type()), o.name))
+		}
+	}
+	return errors.Fail(ErrNoMatch{}, nil, fmt.Sprintf("No match for component %s on object '%s'", typeName(component.Type()), o.name))
+}
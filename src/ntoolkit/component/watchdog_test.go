@@ -0,0 +1,107 @@
+package component_test
+
+import (
+	"bytes"
+	"log/slog"
+	"ntoolkit/assert"
+	"ntoolkit/component"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogReportsHang(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		output := &bytes.Buffer{}
+		runtime := component.NewRuntime(component.Config{
+			LogHandler: slog.NewJSONHandler(output, nil),
+			Watchdog:   10 * time.Millisecond})
+		hang := NewFakeHangComponent("Update")
+		obj := component.NewObject("Stuck")
+		obj.AddComponent(hang)
+		runtime.Root().AddObject(obj)
+
+		runtime.Update(1)
+		time.AfterFunc(50*time.Millisecond, func() { close(hang.Release) })
+		runtime.Update(1)
+		T.Assert(hang.Updates.Load() == 1)
+
+		hangs := runtime.Hangs()
+		T.Assert(len(hangs) == 1)
+		T.Assert(hangs[0].Object == obj)
+		T.Assert(hangs[0].Component == hang)
+		T.Assert(hangs[0].Phase == "Update")
+		T.Assert(hangs[0].Elapsed >= 10*time.Millisecond)
+		T.Assert(!hangs[0].Faulted)
+		T.Assert(strings.Contains(hangs[0].Stack, "FakeHangComponent"))
+
+		records := logRecords(output)
+		T.Assert(len(records) == 1)
+		T.Assert(records[0]["level"] == "ERROR")
+		T.Assert(records[0]["msg"] == "Component exceeded the watchdog deadline")
+		T.Assert(records[0]["object_path"] == "/Stuck")
+		T.Assert(records[0]["component"] == "*ntoolkit/component_test.FakeHangComponent")
+		T.Assert(strings.Contains(records[0]["stack"].(string), "FakeHangComponent"))
+
+		// Without FaultHung the component keeps being updated
+		T.Assert(!obj.Faulted(hang))
+		runtime.Update(1)
+		T.Assert(hang.Updates.Load() == 2)
+		T.Assert(len(runtime.Hangs()) == 1)
+	})
+}
+
+func TestWatchdogFaultsHungComponent(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		runtime := component.NewRuntime(component.Config{
+			Watchdog:     10 * time.Millisecond,
+			FaultHung:    true,
+			StatsHistory: 1})
+		hang := NewFakeHangComponent("Start")
+		others := make([]*FakeComponent, 0)
+		var obj *component.Object
+		for i := 0; i < 200; i++ {
+			child := component.NewObject()
+			if i == 20 {
+				child.AddComponent(hang)
+				obj = child
+			}
+			fake := &FakeComponent{}
+			child.AddComponent(fake)
+			others = append(others, fake)
+			runtime.Root().AddObject(child)
+		}
+
+		// The frame carries on without the hung component, including the rest of its object
+		runtime.Update(1)
+		T.Assert(obj.Faulted(hang))
+		hangs := runtime.Hangs()
+		T.Assert(len(hangs) == 1)
+		T.Assert(hangs[0].Phase == "Start")
+		T.Assert(hangs[0].Faulted)
+		for i := 0; i < len(others); i++ {
+			T.Assert(others[i].Count == 1)
+		}
+		stats := runtime.Stats()
+		T.Assert(stats.Objects == 201)
+		T.Assert(stats.Components == 200)
+		T.Assert(obj.ClearFault(hang) != nil)
+
+		// Later frames skip it
+		runtime.Update(1)
+		T.Assert(hang.Starts.Load() == 1)
+		T.Assert(hang.Updates.Load() == 0)
+		T.Assert(others[0].Count == 2)
+		T.Assert(others[199].Count == 2)
+
+		// Once released and cleared it is updated again
+		close(hang.Release)
+		for deadline := time.Now().Add(time.Second); obj.ClearFault(hang) != nil && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		T.Assert(!obj.Faulted(hang))
+		runtime.Update(1)
+		T.Assert(hang.Updates.Load() == 1)
+		T.Assert(obj.ClearFault(&FakeComponent{}) != nil)
+	})
+}